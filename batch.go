@@ -0,0 +1,151 @@
+package lamlam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// batchFuncKey addresses the envelope carrying several payloads in one invocation.
+const batchFuncKey = "__lamlam.batch"
+
+type (
+	// Batch collects calls to the same lambda and sends them in one invocation.
+	Batch struct {
+		invoker *Invoker
+		items   []batchItem
+	}
+
+	batchItem struct {
+		payload payloadType
+		ret     *Return
+	}
+)
+
+func (i *Invoker) Batch() *Batch {
+	return &Batch{invoker: i}
+}
+
+// Add queues a call, the returned Return is filled by Invoke.
+func (b *Batch) Add(key string, in any) *Return {
+	item := batchItem{
+		payload: payloadType{FuncKey: key},
+		ret:     &Return{},
+	}
+
+	item.ret.err = item.payload.setData(in)
+	b.items = append(b.items, item)
+	return item.ret
+}
+
+func (b *Batch) Len() int {
+	return len(b.items)
+}
+
+// Invoke sends the queued calls, the returned error is about the invocation as a whole.
+// Each item reports its own result and error through the Return given by Add.
+func (b *Batch) Invoke(ctx context.Context) error {
+	h := b.invoker.Func(batchFuncKey)
+	pending := make([]*Return, 0, len(b.items))
+	for i := range b.items {
+		item := &b.items[i]
+		if item.ret.err != nil {
+			continue
+		}
+
+		h.payload.Batch = append(h.payload.Batch, item.payload)
+		pending = append(pending, item.ret)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	data, err := h.withRetry(ctx, func() ([]byte, error) {
+		return h.withCircuit(func() ([]byte, error) {
+			return h.send(ctx)
+		})
+	})
+	if err != nil || len(data) == 0 {
+		for _, ret := range pending {
+			ret.data, ret.err = data, err
+		}
+
+		return (&Return{data: data, err: err}).Result(nil)
+	}
+
+	var results []resultType
+	err = json.Unmarshal(data, &results)
+	if err == nil && len(results) != len(pending) {
+		err = errors.New("batch results count mismatch")
+	}
+	if err != nil {
+		for _, ret := range pending {
+			ret.err = err
+		}
+
+		return err
+	}
+
+	for i, ret := range pending {
+		ret.data, ret.err = results[i].result(ctx, h.blobStore)
+	}
+
+	return nil
+}
+
+// SetBatchConcurrency sets how many items of a batch are dispatched at once, under 2 runs them in order.
+func (m *Mux) SetBatchConcurrency(n int) {
+	m.update(func(t *muxTable) {
+		t.batchConcurrency = n
+	})
+}
+
+func (t *muxTable) dispatchBatch(ctx context.Context, p *payloadType) ([]byte, error) {
+	batch := p.Batch
+	for i := range batch {
+		if batch[i].Metadata == nil {
+			batch[i].Metadata = p.Metadata
+		}
+		if batch[i].Deadline == 0 {
+			batch[i].Deadline = p.Deadline
+		}
+		// the key of the batch stands for all its items, each gets its own
+		if batch[i].IdempotencyKey == "" && p.IdempotencyKey != "" {
+			batch[i].IdempotencyKey = p.IdempotencyKey + "/" + strconv.Itoa(i)
+		}
+	}
+
+	concurrency := t.batchConcurrency
+
+	results := make([]resultType, len(batch))
+	if concurrency < 2 {
+		for i := range batch {
+			res, err := t.dispatch(ctx, &batch[i])
+			results[i] = *t.newResult(ctx, p, res, err)
+		}
+
+		return json.Marshal(results)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := range batch {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			res, err := t.dispatch(ctx, &batch[i])
+			results[i] = *t.newResult(ctx, p, res, err)
+		}(i)
+	}
+	wg.Wait()
+
+	return json.Marshal(results)
+}
@@ -0,0 +1,135 @@
+package lamlam
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// BlobStore keeps bodies too large for a lambda payload, the envelope only carries their reference.
+type BlobStore interface {
+	Put(ctx context.Context, data []byte) (ref string, err error)
+	Get(ctx context.Context, ref string) ([]byte, error)
+}
+
+// FileBlobStore is a BlobStore in a directory the caller and the lambda share, such as an EFS mount.
+// Blobs are named by their sha256 and written through a rename, so a reader never sees one partly written.
+// Nothing is deleted, expiring old blobs is left to the directory.
+type FileBlobStore struct {
+	dir string
+}
+
+var (
+	_ BlobStore = (*FileBlobStore)(nil)
+
+	errBlobStoreNotSet = errors.New("blob reference received, but blob store not set")
+	errInvalidBlobRef  = errors.New("invalid blob reference")
+)
+
+func NewFileBlobStore(dir string) *FileBlobStore {
+	return &FileBlobStore{dir: dir}
+}
+
+// Put stores data under its sha256, so the same body is kept once.
+func (s *FileBlobStore) Put(_ context.Context, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	ref := hex.EncodeToString(sum[:])
+
+	err := os.MkdirAll(s.dir, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ref+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return ref, os.Rename(tmp.Name(), filepath.Join(s.dir, ref))
+}
+
+func (s *FileBlobStore) Get(_ context.Context, ref string) ([]byte, error) {
+	if _, err := hex.DecodeString(ref); err != nil || len(ref) != sha256.Size*2 {
+		return nil, errInvalidBlobRef
+	}
+
+	return os.ReadFile(filepath.Join(s.dir, ref))
+}
+
+func offloadData(ctx context.Context, store BlobStore, limit int, blob *string, data *json.RawMessage) error {
+	if store == nil || limit <= 0 || *blob != "" || len(*data) <= limit {
+		return nil
+	}
+
+	ref, err := store.Put(ctx, *data)
+	if err != nil {
+		return err
+	}
+
+	*blob, *data = ref, nil
+	return nil
+}
+
+func loadData(ctx context.Context, store BlobStore, blob *string, data *json.RawMessage) error {
+	if *blob == "" {
+		return nil
+	}
+	if store == nil {
+		return errBlobStoreNotSet
+	}
+
+	loaded, err := store.Get(ctx, *blob)
+	if err != nil {
+		return err
+	}
+
+	*blob, *data = "", loaded
+	return nil
+}
+
+func (p *payloadType) offload(ctx context.Context, store BlobStore, limit int) error {
+	return offloadData(ctx, store, limit, &p.Blob, &p.Data)
+}
+
+func (p *payloadType) load(ctx context.Context, store BlobStore) error {
+	return loadData(ctx, store, &p.Blob, &p.Data)
+}
+
+func (r *resultType) offload(ctx context.Context, store BlobStore, limit int) error {
+	return offloadData(ctx, store, limit, &r.Blob, &r.Data)
+}
+
+func (r *resultType) load(ctx context.Context, store BlobStore) error {
+	return loadData(ctx, store, &r.Blob, &r.Data)
+}
+
+// SetBlobStore makes Mux offload results larger than limit bytes, after compression, into store
+// for callers accepting it. Requests referencing a blob are loaded from store.
+func (m *Mux) SetBlobStore(store BlobStore, limit int) {
+	m.update(func(t *muxTable) {
+		t.blobStore = store
+		t.blobLimit = limit
+	})
+}
+
+// SetBlobStore makes handlers offload request data larger than limit bytes, after compression, into store.
+// Results referencing a blob are loaded from store.
+func (i *Invoker) SetBlobStore(store BlobStore, limit int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.blobStore = store
+	i.blobLimit = limit
+}
@@ -0,0 +1,235 @@
+package lamlam
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitOpenTimeout      = 30 * time.Second
+	defaultCircuitHalfOpenCalls    = 1
+)
+
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call with ErrCircuitOpen until OpenTimeout passes.
+	CircuitOpen
+	// CircuitHalfOpen lets HalfOpenCalls trial calls through, closing on their success.
+	CircuitHalfOpen
+)
+
+type (
+	CircuitBreakerConfig struct {
+		FailureThreshold int           // consecutive failures opening the circuit, 5 if zero
+		OpenTimeout      time.Duration // how long the circuit stays open, 30s if zero
+		HalfOpenCalls    int           // successful trial calls closing the circuit, 1 if zero
+		PerFuncKey       bool          // break per function name and funcKey instead of per function name
+
+		// IsFailure tells which invocation errors count, by default all.
+		// Errors returned by handlers of Mux never count, canceled calls count neither way.
+		IsFailure func(err error) bool
+
+		// OnStateChange is called on every transition, outside the lock of the breaker.
+		OnStateChange func(name string, from, to CircuitState)
+	}
+
+	// CircuitBreaker stops invoking functions failing hard, it may be shared by several Invokers.
+	CircuitBreaker struct {
+		cfg      CircuitBreakerConfig
+		lock     sync.Mutex
+		circuits map[string]*circuit
+	}
+
+	circuit struct {
+		state      CircuitState
+		generation uint64
+		failures   int
+		successes  int
+		trials     int
+		openedAt   time.Time
+	}
+
+	errCircuitOpen struct{}
+)
+
+var ErrCircuitOpen error = &errCircuitOpen{}
+
+func (err *errCircuitOpen) Error() string {
+	return "circuit open"
+}
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultCircuitFailureThreshold
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = defaultCircuitOpenTimeout
+	}
+	if cfg.HalfOpenCalls <= 0 {
+		cfg.HalfOpenCalls = defaultCircuitHalfOpenCalls
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isCircuitFailure
+	}
+
+	return &CircuitBreaker{
+		cfg:      cfg,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+func isCircuitFailure(err error) bool {
+	return err != nil
+}
+
+// State returns the state of the circuit of name, "function" or "function/funcKey" with PerFuncKey.
+func (b *CircuitBreaker) State(name string) CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if c, ok := b.circuits[name]; ok {
+		return c.state
+	}
+
+	return CircuitClosed
+}
+
+func (b *CircuitBreaker) name(funcName, funcKey string) string {
+	if b.cfg.PerFuncKey {
+		return funcName + "/" + funcKey
+	}
+
+	return funcName
+}
+
+// allow reserves a call on the circuit of name, the returned generation goes back to report.
+func (b *CircuitBreaker) allow(name string) (uint64, error) {
+	b.lock.Lock()
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{}
+		b.circuits[name] = c
+	}
+
+	from := c.state
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= b.cfg.OpenTimeout {
+		c.setState(CircuitHalfOpen)
+	}
+	to := c.state
+
+	var err error
+	switch c.state {
+	case CircuitOpen:
+		err = ErrCircuitOpen
+	case CircuitHalfOpen:
+		if c.trials >= b.cfg.HalfOpenCalls {
+			err = ErrCircuitOpen
+		} else {
+			c.trials++
+		}
+	}
+	generation := c.generation
+	b.lock.Unlock()
+
+	b.notify(name, from, to)
+	return generation, err
+}
+
+// report records the outcome of a call allowed in generation, later transitions make it stale.
+func (b *CircuitBreaker) report(name string, generation uint64, err error) {
+	canceled := errors.Is(err, context.Canceled)
+	failed := !canceled && b.cfg.IsFailure(err)
+
+	b.lock.Lock()
+	c := b.circuits[name]
+	if c.generation != generation {
+		b.lock.Unlock()
+		return
+	}
+
+	// a canceled call tells nothing of the function, its trial goes to the next call
+	if canceled {
+		if c.state == CircuitHalfOpen {
+			c.trials--
+		}
+		b.lock.Unlock()
+		return
+	}
+
+	from := c.state
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+		} else if c.failures++; c.failures >= b.cfg.FailureThreshold {
+			c.setState(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			c.setState(CircuitOpen)
+		} else if c.successes++; c.successes >= b.cfg.HalfOpenCalls {
+			c.setState(CircuitClosed)
+		}
+	}
+	to := c.state
+	b.lock.Unlock()
+
+	b.notify(name, from, to)
+}
+
+func (b *CircuitBreaker) notify(name string, from, to CircuitState) {
+	if from != to && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(name, from, to)
+	}
+}
+
+func (c *circuit) setState(state CircuitState) {
+	c.state = state
+	c.generation++
+	c.failures, c.successes, c.trials = 0, 0, 0
+	if state == CircuitOpen {
+		c.openedAt = time.Now()
+	}
+}
+
+// SetCircuitBreaker makes handlers created by Func afterwards go through breaker.
+func (i *Invoker) SetCircuitBreaker(breaker *CircuitBreaker) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.circuitBreaker = breaker
+}
+
+// withCircuit runs attempt unless the circuit of the handler is open, and reports its outcome.
+func (i *Handler) withCircuit(attempt func() ([]byte, error)) ([]byte, error) {
+	if i.circuitBreaker == nil {
+		return attempt()
+	}
+
+	name := i.circuitBreaker.name(i.funcName, i.payload.FuncKey)
+	generation, err := i.circuitBreaker.allow(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := attempt()
+	i.circuitBreaker.report(name, generation, err)
+	return data, err
+}
@@ -0,0 +1,95 @@
+package lamlam
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// encodingGzip marks data holding a base64 JSON string of the gzip compressed original.
+const encodingGzip = "gzip"
+
+func compressData(encoding *string, data *json.RawMessage, threshold int) error {
+	if threshold <= 0 || *encoding != "" || len(*data) <= threshold {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(*data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	compressed, err := json.Marshal(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	*encoding, *data = encodingGzip, compressed
+	return nil
+}
+
+func decompressData(encoding *string, data *json.RawMessage) error {
+	switch *encoding {
+	case "":
+		return nil
+	case encodingGzip:
+	default:
+		return fmt.Errorf("unsupported encoding %q", *encoding)
+	}
+
+	var compressed []byte
+	if err := json.Unmarshal(*data, &compressed); err != nil {
+		return err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+
+	*encoding, *data = "", decompressed
+	return nil
+}
+
+func (p *payloadType) compress(threshold int) error {
+	return compressData(&p.Encoding, &p.Data, threshold)
+}
+
+func (p *payloadType) decompress() error {
+	return decompressData(&p.Encoding, &p.Data)
+}
+
+func (r *resultType) compress(threshold int) error {
+	return compressData(&r.Encoding, &r.Data, threshold)
+}
+
+func (r *resultType) decompress() error {
+	return decompressData(&r.Encoding, &r.Data)
+}
+
+// SetCompression makes Mux gzip results larger than threshold bytes for callers accepting it, 0 disables it.
+func (m *Mux) SetCompression(threshold int) {
+	m.update(func(t *muxTable) {
+		t.compressThreshold = threshold
+	})
+}
+
+// SetCompression makes handlers gzip request data larger than threshold bytes, 0 disables it.
+// Compressed results are accepted regardless.
+func (i *Invoker) SetCompression(threshold int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.compressThreshold = threshold
+}
@@ -0,0 +1,389 @@
+package lamlam
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultDedupeLease is the longest a lambda runs, so a live call is never taken over.
+const defaultDedupeLease = 15 * time.Minute
+
+type (
+	// DedupeStore keeps the result of every idempotency key, so Mux runs a key once.
+	DedupeStore interface {
+		// Begin marks key in progress and returns nil, or returns the record already kept for key.
+		// A key in progress past its lease is taken over, its lambda was likely killed before Abort.
+		Begin(ctx context.Context, key string) (*DedupeRecord, error)
+		// Complete saves the result of key.
+		Complete(ctx context.Context, key string, rec *DedupeRecord) error
+		// Abort forgets key, so the next call with it runs the handler again.
+		Abort(ctx context.Context, key string) error
+	}
+
+	// DedupeRecord is the state of an idempotency key, in progress until Done.
+	DedupeRecord struct {
+		Done    bool            `json:"done"`
+		Data    json.RawMessage `json:"data,omitempty"`
+		Error   *ErrorPayload   `json:"error,omitempty"`
+		Digest  string          `json:"digest,omitempty"`  // of the data and schema hash of the call
+		Expires int64           `json:"expires,omitempty"` // unix milliseconds, zero never expires
+	}
+
+	// MemoryDedupeStore is a DedupeStore in memory, shared by invocations of the same lambda instance.
+	MemoryDedupeStore struct {
+		lock      sync.Mutex
+		ttl       time.Duration
+		lease     time.Duration
+		records   map[string]DedupeRecord
+		nextSweep time.Time
+	}
+
+	// FileDedupeStore is a DedupeStore in a directory, which instances of a lambda may share through an EFS mount.
+	// A key is claimed by creating its file exclusively and an expired lease is taken over by renaming it,
+	// so a key runs once, unless a claim made between a takeover and its giving back is lost, letting two calls run.
+	FileDedupeStore struct {
+		dir   string
+		ttl   time.Duration
+		lease time.Duration
+	}
+
+	idempotencyKeyKey struct{}
+)
+
+var (
+	_ DedupeStore = (*MemoryDedupeStore)(nil)
+	_ DedupeStore = (*FileDedupeStore)(nil)
+)
+
+// WithIdempotencyKey returns a copy of ctx whose calls carry key, unless the Handler sets its own.
+// A call with the key of an earlier one to the same funcKey but other data fails with ErrIdempotencyMismatch,
+// items of a batch each get a key of their own derived from it.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return key
+}
+
+// IdempotencyKey makes Mux run the handler once per key, repeats get the saved result.
+// Mux only deduplicates when it has a DedupeStore.
+func (i *Handler) IdempotencyKey(key string) *Handler {
+	i.payload.IdempotencyKey = key
+	return i
+}
+
+// SetDedupeStore makes Mux keep the result of calls carrying an idempotency key in store.
+func (m *Mux) SetDedupeStore(store DedupeStore) {
+	m.update(func(t *muxTable) {
+		t.dedupeStore = store
+	})
+}
+
+// dedupe runs next once per idempotency key of p, scoped to funcKey.
+func dedupe(ctx context.Context, store DedupeStore, funcKey string, p *payloadType, next DispatchFunc) (res []byte, err error) {
+	key := funcKey + "/" + p.IdempotencyKey
+	rec, err := store.Begin(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	digest := dedupeDigest(p)
+	if rec != nil {
+		if rec.Done && rec.Digest != "" && rec.Digest != digest {
+			return nil, ErrIdempotencyMismatch
+		}
+
+		return rec.result()
+	}
+
+	returned := false
+	defer func() {
+		// a panic leaves the key to the next call
+		if !returned {
+			_ = store.Abort(ctx, key)
+		}
+	}()
+
+	res, err = next(ctx, funcKey, p)
+	returned = true
+
+	if !isFinalResult(err) {
+		_ = store.Abort(ctx, key)
+		return
+	}
+
+	rec = &DedupeRecord{Done: true, Data: res, Digest: digest}
+	if err != nil {
+		rec.Data, rec.Error = nil, newErrorPayload(err)
+	}
+
+	// the handler already ran, without the record a repeat runs it again
+	if store.Complete(ctx, key, rec) != nil {
+		_ = store.Abort(ctx, key)
+	}
+
+	return
+}
+
+// dedupeDigest tells calls reusing a key with other data apart from repeats.
+func dedupeDigest(p *payloadType) string {
+	h := sha256.New()
+	h.Write(p.Data)
+	h.Write([]byte{0})
+	h.Write([]byte(p.SchemaHash))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isFinalResult reports whether err is worth saving, errors a retry may cure are not.
+func isFinalResult(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	return DefaultRetryClassifier(err) == RetryNever &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+func (r *DedupeRecord) result() ([]byte, error) {
+	switch {
+	case !r.Done:
+		return nil, ErrInProgress
+	case r.Error != nil:
+		return nil, r.Error
+	}
+
+	return r.Data, nil
+}
+
+func (r *DedupeRecord) expired(now time.Time) bool {
+	return r.Expires > 0 && now.UnixMilli() >= r.Expires
+}
+
+func dedupeExpires(now time.Time, ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	return now.Add(ttl).UnixMilli()
+}
+
+// NewMemoryDedupeStore keeps results for ttl after they complete, zero keeps them forever.
+func NewMemoryDedupeStore(ttl time.Duration) *MemoryDedupeStore {
+	return &MemoryDedupeStore{
+		ttl:     ttl,
+		lease:   defaultDedupeLease,
+		records: make(map[string]DedupeRecord),
+	}
+}
+
+// SetLease sets how long a key stays in progress before another call may take it over, 15 minutes by default.
+func (s *MemoryDedupeStore) SetLease(lease time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lease = lease
+}
+
+func (s *MemoryDedupeStore) Begin(_ context.Context, key string) (*DedupeRecord, error) {
+	now := time.Now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sweep(now)
+
+	if rec, ok := s.records[key]; ok && !rec.expired(now) {
+		return &rec, nil
+	}
+
+	s.records[key] = DedupeRecord{Expires: dedupeExpires(now, s.lease)}
+	return nil, nil
+}
+
+func (s *MemoryDedupeStore) Complete(_ context.Context, key string, rec *DedupeRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	saved := *rec
+	saved.Expires = dedupeExpires(time.Now(), s.ttl)
+	s.records[key] = saved
+	return nil
+}
+
+func (s *MemoryDedupeStore) Abort(_ context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// sweep drops expired records at most once per ttl, or per lease when shorter.
+func (s *MemoryDedupeStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+
+	interval := s.ttl
+	if interval <= 0 || (s.lease > 0 && s.lease < interval) {
+		interval = s.lease
+	}
+	if interval <= 0 {
+		return
+	}
+
+	for key, rec := range s.records {
+		if rec.expired(now) {
+			delete(s.records, key)
+		}
+	}
+	s.nextSweep = now.Add(interval)
+}
+
+// NewFileDedupeStore keeps results as files in dir for ttl after they complete, zero keeps them forever.
+func NewFileDedupeStore(dir string, ttl time.Duration) *FileDedupeStore {
+	return &FileDedupeStore{dir: dir, ttl: ttl, lease: defaultDedupeLease}
+}
+
+// SetLease sets how long a key stays in progress before another call may take it over, 15 minutes by default.
+// It is not safe to call while the store is in use.
+func (s *FileDedupeStore) SetLease(lease time.Duration) {
+	s.lease = lease
+}
+
+func (s *FileDedupeStore) Begin(_ context.Context, key string) (*DedupeRecord, error) {
+	err := os.MkdirAll(s.dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	path := s.path(key)
+	for {
+		now := time.Now()
+		data, err := json.Marshal(&DedupeRecord{Expires: dedupeExpires(now, s.lease)})
+		if err != nil {
+			return nil, err
+		}
+
+		// creating the file exclusively is the claim on key
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			return nil, err
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		data, err = os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// a file still being written is in progress, until its lease runs out
+		var rec DedupeRecord
+		if json.Unmarshal(data, &rec) != nil {
+			info, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			rec = DedupeRecord{Expires: dedupeExpires(info.ModTime(), s.lease)}
+		}
+
+		if !rec.expired(now) {
+			return &rec, nil
+		}
+
+		err = s.takeover(path, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// takeover moves the expired claim in path out of the way, so the exclusive create claims key again.
+// Renaming it succeeds for one caller only, unlike removing it, which could remove a claim made meanwhile.
+func (s *FileDedupeStore) takeover(path string, expired []byte) error {
+	stale := fmt.Sprintf("%s.%d-%d.stale", path, os.Getpid(), time.Now().UnixNano())
+	err := os.Rename(path, stale)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer os.Remove(stale)
+
+	data, err := os.ReadFile(stale)
+	if err != nil {
+		return err
+	}
+
+	// another caller took over first and claimed key, give its claim back
+	if !bytes.Equal(data, expired) {
+		err = os.Link(stale, path)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FileDedupeStore) Complete(_ context.Context, key string, rec *DedupeRecord) error {
+	path := s.path(key)
+
+	saved := *rec
+	saved.Expires = dedupeExpires(time.Now(), s.ttl)
+	data, err := json.Marshal(&saved)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileDedupeStore) Abort(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *FileDedupeStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
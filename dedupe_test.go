@@ -0,0 +1,111 @@
+package lamlam
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newDedupeInvoker(t *testing.T, calls *int32) *Invoker {
+	m := NewMux()
+	m.SetDedupeStore(NewMemoryDedupeStore(time.Minute))
+	err := m.Set("echo", func(in string) (string, error) {
+		atomic.AddInt32(calls, 1)
+		return in, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewInvoker(NewLoopback(m), "f")
+}
+
+func TestDedupeMismatch(t *testing.T) {
+	var calls int32
+	inv := newDedupeInvoker(t, &calls)
+	ctx := WithIdempotencyKey(context.Background(), "k")
+
+	var res string
+	for i := 0; i < 2; i++ {
+		if err := inv.Invoke(ctx, "echo", "a").Result(&res); err != nil || res != "a" {
+			t.Fatalf("repeat %d: %q, %v", i, res, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("handler ran %d times", calls)
+	}
+
+	err := inv.Invoke(ctx, "echo", "b").Result(&res)
+	if !errors.Is(err, ErrIdempotencyMismatch) {
+		t.Fatalf("other data: %v", err)
+	}
+}
+
+func TestDedupeBatch(t *testing.T) {
+	var calls int32
+	inv := newDedupeInvoker(t, &calls)
+	ctx := WithIdempotencyKey(context.Background(), "k")
+
+	for i := 0; i < 2; i++ {
+		batch := inv.Batch()
+		a, b := batch.Add("echo", "a"), batch.Add("echo", "b")
+		if err := batch.Invoke(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		var resA, resB string
+		if err := a.Result(&resA); err != nil || resA != "a" {
+			t.Fatalf("item a: %q, %v", resA, err)
+		}
+		if err := b.Result(&resB); err != nil || resB != "b" {
+			t.Fatalf("item b: %q, %v", resB, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("handler ran %d times", calls)
+	}
+}
+
+func TestFileDedupeStoreTakeover(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileDedupeStore(t.TempDir(), time.Minute)
+	store.SetLease(10 * time.Millisecond)
+
+	if rec, err := store.Begin(ctx, "k"); rec != nil || err != nil {
+		t.Fatalf("first begin: %v, %v", rec, err)
+	}
+	if rec, err := store.Begin(ctx, "k"); rec == nil || rec.Done || err != nil {
+		t.Fatalf("in progress: %v, %v", rec, err)
+	}
+
+	// the lambda holding the lease was killed, of several takers exactly one claims key
+	time.Sleep(20 * time.Millisecond)
+	var claimed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec, err := store.Begin(ctx, "k")
+			if err != nil {
+				t.Error(err)
+			}
+			if rec == nil {
+				atomic.AddInt32(&claimed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Fatalf("%d takers claimed key", claimed)
+	}
+
+	entries, err := os.ReadDir(store.dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("files left: %d, %v", len(entries), err)
+	}
+}
@@ -0,0 +1,228 @@
+package lamlam
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// describeFuncKey asks Mux for the Description of every registered funcKey.
+const describeFuncKey = "__lamlam.describe"
+
+type (
+	// Description is what a Mux serves, schemas refer to named types in Defs.
+	Description struct {
+		Funcs []FuncDescription      `json:"funcs"`
+		Defs  map[string]*JSONSchema `json:"$defs,omitempty"`
+	}
+
+	// FuncDescription describes a funcKey, Input and Output are nil when the function takes or returns no data.
+	FuncDescription struct {
+		FuncKey string      `json:"funcKey"`
+		Input   *JSONSchema `json:"input,omitempty"`
+		Output  *JSONSchema `json:"output,omitempty"`
+	}
+
+	// JSONSchema is the subset of JSON Schema Mux derives from Go types.
+	JSONSchema struct {
+		Ref                  string                 `json:"$ref,omitempty"`
+		Type                 string                 `json:"type,omitempty"`
+		Format               string                 `json:"format,omitempty"`
+		ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+		Items                *JSONSchema            `json:"items,omitempty"`
+		PrefixItems          []*JSONSchema          `json:"prefixItems,omitempty"`
+		Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+		AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+		Required             []string               `json:"required,omitempty"`
+	}
+
+	schemaBuilder struct {
+		defs map[string]*JSONSchema
+	}
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Describe asks the lambda what it serves.
+func (i *Invoker) Describe(ctx context.Context) (desc *Description, err error) {
+	desc = &Description{}
+	err = i.Invoke(ctx, describeFuncKey, nil).Result(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (t *muxTable) describe() ([]byte, error) {
+	funcKeys := make([]string, 0, len(t.funcTable))
+	for funcKey := range t.funcTable {
+		funcKeys = append(funcKeys, funcKey)
+	}
+	sort.Strings(funcKeys)
+
+	b := &schemaBuilder{defs: make(map[string]*JSONSchema)}
+	desc := &Description{Funcs: make([]FuncDescription, 0, len(funcKeys))}
+	for _, funcKey := range funcKeys {
+		desc.Funcs = append(desc.Funcs, t.funcTable[funcKey].describe(funcKey, b))
+	}
+	if len(b.defs) > 0 {
+		desc.Defs = b.defs
+	}
+
+	return json.Marshal(desc)
+}
+
+func (h *handler) describe(funcKey string, b *schemaBuilder) FuncDescription {
+	desc := FuncDescription{FuncKey: funcKey}
+
+	switch h.inputKind {
+	case inputDataOnly:
+		desc.Input = b.schema(h.funcInputType[0])
+	case inputBoth:
+		desc.Input = b.schema(h.funcInputType[1])
+	case inputParams:
+		types := h.funcInputType
+		if h.withContext {
+			types = types[1:]
+		}
+
+		// Mux rejects params missing from the object
+		desc.Input = &JSONSchema{
+			Type:       "object",
+			Properties: make(map[string]*JSONSchema, len(types)),
+			Required:   append([]string(nil), h.paramNames...),
+		}
+		for i, typ := range types {
+			desc.Input.Properties[h.paramNames[i]] = b.schema(typ)
+		}
+	}
+
+	switch h.outputKind {
+	case outputDataOnly, outputBoth:
+		desc.Output = b.schema(h.funcOutputType[0])
+	case outputResults:
+		types := h.funcOutputType
+		if h.withError {
+			types = types[:len(types)-1]
+		}
+
+		desc.Output = &JSONSchema{Type: "array", PrefixItems: make([]*JSONSchema, 0, len(types))}
+		for _, typ := range types {
+			desc.Output.PrefixItems = append(desc.Output.PrefixItems, b.schema(typ))
+		}
+	}
+
+	return desc
+}
+
+// schema returns the schema of typ as encoding/json encodes it, named structs go to defs.
+func (b *schemaBuilder) schema(typ reflect.Type) *JSONSchema {
+	switch {
+	case typ == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case typ == rawMessageType:
+		return &JSONSchema{}
+	case typ.Implements(jsonMarshalerType) || reflect.PtrTo(typ).Implements(jsonMarshalerType):
+		return &JSONSchema{}
+	case typ.Implements(textMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType):
+		return &JSONSchema{Type: "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Ptr:
+		return b.schema(typ.Elem())
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 && !reflect.PtrTo(typ.Elem()).Implements(textUnmarshalerType) {
+			return &JSONSchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &JSONSchema{Type: "array", Items: b.schema(typ.Elem())}
+	case reflect.Array:
+		return &JSONSchema{Type: "array", Items: b.schema(typ.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: b.schema(typ.Elem())}
+	case reflect.Struct:
+		name := qualifiedTypeName(typ)
+		if name == "" {
+			return b.structSchema(typ)
+		}
+
+		if _, ok := b.defs[name]; !ok {
+			// reserve the name first, so recursive types refer to themselves
+			b.defs[name] = nil
+			b.defs[name] = b.structSchema(typ)
+		}
+
+		return &JSONSchema{Ref: "#/$defs/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)}
+	}
+
+	// interfaces hold anything
+	return &JSONSchema{}
+}
+
+func (b *schemaBuilder) structSchema(typ reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	b.addFields(schema, typ)
+	return schema
+}
+
+// addFields adds the fields of typ to schema, promoting the fields of embedded structs without a json name.
+func (b *schemaBuilder) addFields(schema *JSONSchema, typ reflect.Type) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			b.addFields(schema, fieldType)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldSchema := b.schema(field.Type)
+		if strings.Contains(opts+",", ",string,") {
+			fieldSchema = &JSONSchema{Type: "string"}
+		}
+		schema.Properties[name] = fieldSchema
+
+		if !strings.Contains(opts+",", ",omitempty,") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -1,20 +1,31 @@
 package lamlam
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/aws/aws-lambda-go/lambda/messages"
 	"reflect"
+	"strings"
 )
 
 type UnmarshalerErrorPayload interface {
 	UnmarshalErrorPayload(ep *ErrorPayload) error
 }
 
+// MarshalerErrorPayload is implemented by errors that carry fields across the wire,
+// usually by setting ep.ErrorData.
+type MarshalerErrorPayload interface {
+	MarshalErrorPayload(ep *ErrorPayload) error
+}
+
 var _ error = (*ErrorPayload)(nil)
 
 type ErrorPayload struct {
-	ErrorMessage string `json:"errorMessage"`
-	ErrorType    string `json:"errorType"`
+	ErrorMessage string          `json:"errorMessage"`
+	ErrorType    string          `json:"errorType"`
+	ErrorData    json.RawMessage `json:"errorData,omitempty"`
 }
 
 func (e *ErrorPayload) Error() string {
@@ -22,7 +33,19 @@ func (e *ErrorPayload) Error() string {
 }
 
 func (e *ErrorPayload) Is(err error) bool {
-	return e.ErrorType == getTypeName(reflect.TypeOf(err))
+	if code, ok := lookupErrorCode(err); ok {
+		return e.ErrorType == code
+	}
+
+	// bare names only come from the lambda runtime, Mux always qualifies them
+	typ := reflect.TypeOf(err)
+	name := qualifiedTypeName(typ)
+	if isBareTypeName(e.ErrorType) {
+		name = getTypeName(typ)
+	}
+
+	// unexported types like errors.errorString only tell apart by message
+	return e.ErrorType == name && (isExportedType(typ) || e.ErrorMessage == err.Error())
 }
 
 func (e *ErrorPayload) As(target any) bool {
@@ -38,24 +61,90 @@ func (e *ErrorPayload) As(target any) bool {
 	if targetType.Kind() != reflect.Interface && !targetType.Implements(errorType) {
 		return false
 	}
-	if e.ErrorType == getTypeName(targetType) {
-		targetValue := val.Elem()
+	name := qualifiedTypeName(targetType)
+	if isBareTypeName(e.ErrorType) {
+		name = getTypeName(targetType)
+	}
+	if e.ErrorType == name {
+		dst := target
 		if targetType.Kind() == reflect.Ptr {
-			targetValue.Set(reflect.New(targetType.Elem()))
+			targetValue := reflect.New(targetType.Elem())
+			val.Elem().Set(targetValue)
+			dst = targetValue.Interface()
 		}
 
-		if unmarshal, ok := targetValue.Interface().(UnmarshalerErrorPayload); ok {
-			err := unmarshal.UnmarshalErrorPayload(e)
-			if err != nil {
-				return false
-			}
-		}
-		return true
+		return e.unmarshalTo(dst) == nil
 	}
 
 	return false
 }
 
+func (e *ErrorPayload) unmarshalTo(dst interface{}) error {
+	if unmarshal, ok := dst.(UnmarshalerErrorPayload); ok {
+		return unmarshal.UnmarshalErrorPayload(e)
+	}
+
+	if len(e.ErrorData) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(e.ErrorData, dst)
+}
+
+// newErrorPayload encodes err for the result envelope, named by its qualified type.
+// Registered errors found in the chain of err name their code or qualified type instead,
+// errors implementing MarshalerErrorPayload fill ErrorData as well.
+func newErrorPayload(err error) *ErrorPayload {
+	switch err := err.(type) {
+	case *ErrorPayload:
+		return err
+	case messages.InvokeResponse_Error:
+		return &ErrorPayload{
+			ErrorMessage: err.Message,
+			ErrorType:    err.Type,
+		}
+	}
+
+	ep := &ErrorPayload{
+		ErrorMessage: err.Error(),
+		ErrorType:    qualifiedTypeName(reflect.TypeOf(err)),
+	}
+
+	if name, found, ok := lookupRegisteredError(err); ok {
+		ep.ErrorType = name
+		err = found
+	}
+
+	if marshal, ok := err.(MarshalerErrorPayload); ok {
+		if marshal.MarshalErrorPayload(ep) != nil {
+			ep.ErrorData = nil
+		}
+	}
+
+	return ep
+}
+
+// newRuntimeErrorPayload encodes err the way the lambda runtime reports a handler error,
+// by bare type name and message only.
+func newRuntimeErrorPayload(err error) *ErrorPayload {
+	if err, ok := err.(messages.InvokeResponse_Error); ok {
+		return &ErrorPayload{
+			ErrorMessage: err.Message,
+			ErrorType:    err.Type,
+		}
+	}
+
+	return &ErrorPayload{
+		ErrorMessage: err.Error(),
+		ErrorType:    getTypeName(reflect.TypeOf(err)),
+	}
+}
+
+// isBareTypeName reports whether name lacks the package the lambda runtime leaves out.
+func isBareTypeName(name string) bool {
+	return !strings.Contains(name, ".")
+}
+
 func getTypeName(typ reflect.Type) string {
 	for typ.Kind() == reflect.Ptr {
 		typ = typ.Elem()
@@ -73,6 +162,10 @@ func (e *ErrorPayload) TryCast(err error) error {
 }
 
 func (e *ErrorPayload) TryCastKnownError() error {
+	if err, ok := newRegisteredError(e.ErrorType, e); ok {
+		return err
+	}
+
 	err := knownErrTable[e.ErrorType]
 	if err != nil {
 		return err
@@ -95,11 +188,62 @@ func (err *errNotFoundFunction) Error() string {
 	return "not found function"
 }
 
-var (
-	ErrNotFoundFunction error = &errNotFoundFunction{}
-	ErrUnhandled              = errors.New("Unhandled")
+type errDeadlineExceeded struct{}
 
+func (err *errDeadlineExceeded) Error() string {
+	return "deadline exceeded before call"
+}
+
+func (err *errDeadlineExceeded) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+type errSchemaMismatch struct{}
+
+func (err *errSchemaMismatch) Error() string {
+	return "schema of caller and handler mismatch"
+}
+
+type errIdempotencyMismatch struct{}
+
+func (err *errIdempotencyMismatch) Error() string {
+	return "idempotency key reused with different data"
+}
+
+type errInProgress struct{}
+
+func (err *errInProgress) Error() string {
+	return "call with the same idempotency key in progress"
+}
+
+var (
+	ErrNotFoundFunction    error = &errNotFoundFunction{}
+	ErrDeadlineExceeded    error = &errDeadlineExceeded{}
+	ErrInProgress          error = &errInProgress{}
+	ErrIdempotencyMismatch error = &errIdempotencyMismatch{}
+	ErrLimitExceeded       error = &errLimitExceeded{}
+	ErrSchemaMismatch      error = &errSchemaMismatch{}
+	ErrUnhandled                 = errors.New("Unhandled")
+	ErrPanic               error = &PanicError{}
+
+	// knownErrTable resolves the bare type names reported by the lambda runtime
 	knownErrTable = map[string]error{
-		"errNotFoundFunction": ErrNotFoundFunction,
+		"errNotFoundFunction":    ErrNotFoundFunction,
+		"PanicError":             ErrPanic,
+		"errDeadlineExceeded":    ErrDeadlineExceeded,
+		"errInProgress":          ErrInProgress,
+		"errIdempotencyMismatch": ErrIdempotencyMismatch,
+		"errLimitExceeded":       ErrLimitExceeded,
+		"errSchemaMismatch":      ErrSchemaMismatch,
 	}
 )
+
+func init() {
+	RegisterErrorCode("lamlam.ErrNotFoundFunction", ErrNotFoundFunction)
+	RegisterErrorCode("lamlam.ErrDeadlineExceeded", ErrDeadlineExceeded)
+	RegisterErrorCode("lamlam.ErrInProgress", ErrInProgress)
+	RegisterErrorCode("lamlam.ErrIdempotencyMismatch", ErrIdempotencyMismatch)
+	RegisterErrorCode("lamlam.ErrLimitExceeded", ErrLimitExceeded)
+	RegisterErrorCode("lamlam.ErrSchemaMismatch", ErrSchemaMismatch)
+	RegisterError(ErrPanic)
+}
@@ -0,0 +1,133 @@
+package lamlam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// CallOption configures the Handler of every call made through a function returned by Call.
+type CallOption func(h *Handler)
+
+// CallIdempotent marks the calls idempotent, see Handler.Idempotent.
+func CallIdempotent() CallOption {
+	return func(h *Handler) {
+		h.Idempotent()
+	}
+}
+
+// CallSchemaHash sends hash with the calls, see Handler.SchemaHash.
+func CallSchemaHash(hash string) CallOption {
+	return func(h *Handler) {
+		h.SchemaHash(hash)
+	}
+}
+
+// Call returns a typed function calling key through invoker.
+func Call[In, Out any](invoker *Invoker, key string, opts ...CallOption) func(ctx context.Context, in In) (Out, error) {
+	return func(ctx context.Context, in In) (out Out, err error) {
+		h := invoker.Func(key)
+		for _, opt := range opts {
+			opt(h)
+		}
+
+		err = h.Invoke(ctx, in).Result(&out)
+		return
+	}
+}
+
+// Handle sets fn under funcKey like Mux.Set, but calls it without reflection.
+func Handle[In, Out any](m *Mux, funcKey string, fn func(ctx context.Context, in In) (Out, error), opts ...SetOption) error {
+	funcValue := reflect.ValueOf(fn)
+	h := &handler{
+		funcValue: funcValue,
+		funcType:  funcValue.Type(),
+		call: func(ctx context.Context, p *Payload) (any, error) {
+			var in In
+			err := p.Bind(&in)
+			if err != nil {
+				return nil, err
+			}
+
+			return fn(ctx, in)
+		},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return m.setHandler(funcKey, h)
+}
+
+// Register sets every method of the interface T, implemented by receiver, under prefix + "." + method name,
+// the funcKeys lamlam gen names FuncKey constants after, such as "Svc.UserService.Get".
+// Methods of receiver outside T stay unreachable. Methods taking several data arguments must be named
+// in paramNames, reflection can't tell the names callers key their arguments by, see WithParamNames.
+// Nothing is set unless every method can be.
+func Register[T any](m *Mux, prefix string, receiver T, paramNames map[string][]string, opts ...SetOption) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Interface {
+		return fmt.Errorf("%s is not an interface", typ)
+	}
+
+	val := reflect.ValueOf(receiver)
+	if !val.IsValid() || isNilValue(val) {
+		return errors.New("nil receiver")
+	}
+
+	handlers := make(map[string]*handler, typ.NumMethod())
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		if method.PkgPath != "" {
+			continue
+		}
+
+		funcKey := prefix + "." + method.Name
+		if err := checkFuncKey(funcKey); err != nil {
+			return err
+		}
+
+		numData := method.Type.NumIn()
+		if numData > 0 && method.Type.In(0) == contextType {
+			numData--
+		}
+
+		setOpts := opts
+		if names, ok := paramNames[method.Name]; ok {
+			setOpts = append(opts[:len(opts):len(opts)], WithParamNames(names...))
+		} else if numData > 1 {
+			return fmt.Errorf("%s: several data arguments need param names", funcKey)
+		}
+
+		funcValue := val.MethodByName(method.Name)
+		h := &handler{
+			funcValue: funcValue,
+			funcType:  funcValue.Type(),
+		}
+		for _, opt := range setOpts {
+			opt(h)
+		}
+
+		if err := h.init(); err != nil {
+			return fmt.Errorf("%s: %w", funcKey, err)
+		}
+		handlers[funcKey] = h
+	}
+
+	m.update(func(t *muxTable) {
+		for funcKey, h := range handlers {
+			t.funcTable[funcKey] = h
+		}
+	})
+	return nil
+}
+
+func isNilValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return val.IsNil()
+	}
+
+	return false
+}
@@ -1,6 +1,6 @@
 module github.com/stockfolioofficial/lamlam
 
-go 1.17
+go 1.18
 
 require (
 	github.com/aws/aws-lambda-go v1.34.1
@@ -1,4 +0,0 @@
-package lamlam
-
-//TODO: Need remove on go1.18
-type any interface{}
@@ -21,6 +21,9 @@ type genFuncKeyPair struct {
 	pkgPath       string
 	interfaceName string
 	methodName    string
+	schemaHash    string
+	paramNames    []string
+	typed         bool
 }
 
 type genMux struct {
@@ -49,11 +52,15 @@ type genImplementMethod struct {
 	methodName string
 	params     []genImplementMethodValue
 	results    []genImplementMethodValue
+	idempotent bool
+	typed      bool
 }
 
 type genImplementMethodValue struct {
 	pkgPaths []string
 	typ      string
+	name     string
+	variadic bool
 }
 
 type importData struct {
@@ -97,12 +104,112 @@ func (m methodData) name() string {
 	return ""
 }
 
+type methodParam struct {
+	name     string
+	typ      types.Type
+	variadic bool
+}
+
+// methodParams returns the parameters of the method, one per name.
+// Unnamed data parameters are named argN after their position among data parameters, like Mux does.
+func (m methodData) methodParams() []methodParam {
+	params := make([]methodParam, 0, len(m.params))
+	for _, field := range m.params {
+		expr, variadic := field.Type, false
+		if ellipsis, ok := expr.(*ast.Ellipsis); ok {
+			expr, variadic = ellipsis.Elt, true
+		}
+		typ := m.pkg.TypesInfo.TypeOf(expr)
+
+		names := make([]string, 0, len(field.Names))
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) == 0 {
+			names = append(names, "")
+		}
+
+		for _, name := range names {
+			params = append(params, methodParam{name: name, typ: typ, variadic: variadic})
+		}
+	}
+
+	n := 0
+	for i := range params {
+		if isContextType(params[i].typ) {
+			continue
+		}
+		if params[i].name == "" || params[i].name == "_" {
+			params[i].name = fmt.Sprintf("arg%d", n)
+		}
+		n++
+	}
+
+	return params
+}
+
+// dataParamNames returns the names keying the data of a method taking several data parameters, nil otherwise.
+func (m methodData) dataParamNames() []string {
+	var names []string
+	for _, param := range m.methodParams() {
+		if !isContextType(param.typ) {
+			names = append(names, param.name)
+		}
+	}
+
+	if len(names) < 2 {
+		return nil
+	}
+
+	return names
+}
+
+// typed reports whether the method has the shape lamlam.Call and lamlam.Handle take,
+// func(ctx context.Context, in In) (Out, error).
+func (m methodData) typed() bool {
+	params := m.methodParams()
+	if len(params) != 2 || !isContextType(params[0].typ) || isContextType(params[1].typ) || params[1].variadic {
+		return false
+	}
+
+	var results []types.Type
+	for _, field := range m.results {
+		typ := m.pkg.TypesInfo.TypeOf(field.Type)
+		for n := 0; n < len(field.Names) || n == 0; n++ {
+			results = append(results, typ)
+		}
+	}
+
+	return len(results) == 2 && !isErrorType(results[0]) && isErrorType(results[1])
+}
+
+// idempotent reports whether the doc comment of the method has the //lamlam:idempotent directive.
+func (m methodData) idempotent() bool {
+	if m.field.Doc == nil {
+		return false
+	}
+
+	for _, c := range m.field.Doc.List {
+		if strings.TrimSpace(c.Text) == idempotentDirective {
+			return true
+		}
+	}
+
+	return false
+}
+
 func gen(pkgs []*packages.Package, lambda *config.Lambda) ([]byte, error) {
 	moduleName, err := getCurrentModuleName()
 	if err != nil {
 		return nil, err
 	}
 
+	goVersion, err := getCurrentModuleGoVersion()
+	if err != nil {
+		return nil, err
+	}
+	generics := supportsGenerics(goVersion)
+
 	outputPkgPath := filepath.Join(moduleName, lambda.Output)
 	targetTypeTable := make(map[string]*config.InterfaceType)
 	for i := range lambda.Type {
@@ -210,6 +317,7 @@ func gen(pkgs []*packages.Package, lambda *config.Lambda) ([]byte, error) {
 	}
 
 	usedImports := make(map[string]*importData)
+	sigTable := make(map[string]*genMethodSignature)
 	for i := range handler.implements {
 		impl := &handler.implements[i]
 		if imp := importTable[impl.pkgPath]; imp != nil {
@@ -273,7 +381,9 @@ func gen(pkgs []*packages.Package, lambda *config.Lambda) ([]byte, error) {
 		b.WriteString("\"\n")
 		existsPackageNameTable[pkgName]++
 	}
-	b.WriteString("\t\"github.com/aws/aws-sdk-go-v2/service/lambda\"\n")
+	if usedImports["context"] == nil {
+		b.WriteString("\t\"context\"\n")
+	}
 	b.WriteString("\t\"github.com/stockfolioofficial/lamlam\"\n")
 	b.WriteString(")\n\n")
 
@@ -298,14 +408,39 @@ func gen(pkgs []*packages.Package, lambda *config.Lambda) ([]byte, error) {
 		b.WriteString(value)
 		b.WriteString("\"\n")
 	}
+
+	// schema hashes let Mux tell callers built from another version of the interface
+	schemaHashNameTable := make(map[string]string)
+	paramNamesTable := make(map[string][]string)
+	typedTable := make(map[string]bool)
+	for i := range funcKey.keys {
+		key := &funcKey.keys[i]
+		pkgPath := convertUpperCamelCasePkgPath(strings.TrimPrefix(key.pkgPath, moduleName))
+
+		name := fmt.Sprintf("SchemaHash%s%s%s", pkgPath, key.interfaceName, key.methodName)
+		schemaHashNameTable[pkgPath+key.interfaceName+key.methodName] = name
+		paramNamesTable[pkgPath+key.interfaceName+key.methodName] = key.paramNames
+		typedTable[pkgPath+key.interfaceName+key.methodName] = generics && key.typed
+
+		b.WriteRune('\t')
+		b.WriteString(name)
+		b.WriteString(" = \"")
+		b.WriteString(key.schemaHash)
+		b.WriteString("\"\n")
+	}
 	b.WriteString(")\n\n")
 
+	b.WriteString("// Preflight checks LambdaName exists and may be invoked, meant for service boot.\n")
+	b.WriteString("func Preflight(ctx context.Context, cli lamlam.Transport) error {\n")
+	b.WriteString("\treturn lamlam.Preflight(ctx, cli, lamlam.PreflightDryRun, LambdaName)\n")
+	b.WriteString("}\n\n")
+
 	for i := range handler.implements {
 		impl := &handler.implements[i]
 		pkgPath := convertUpperCamelCasePkgPath(strings.TrimPrefix(impl.pkgPath, moduleName))
 
 		genTypeName := fmt.Sprintf("handler%s%sImpl", pkgPath, impl.typName)
-		b.WriteString(fmt.Sprintf("func New%s%sHandler(cli *lambda.Client) %s.%s {\n", pkgPath, impl.typName, packageNameTable[impl.pkgPath], impl.typName))
+		b.WriteString(fmt.Sprintf("func New%s%sHandler(cli lamlam.Transport) %s.%s {\n", pkgPath, impl.typName, packageNameTable[impl.pkgPath], impl.typName))
 		b.WriteString("\treturn &")
 		b.WriteString(genTypeName)
 		b.WriteString("{\n")
@@ -319,96 +454,56 @@ func gen(pkgs []*packages.Package, lambda *config.Lambda) ([]byte, error) {
 		b.WriteString("\tinvoker *lamlam.Invoker\n")
 		b.WriteString("}\n\n")
 
+		var asyncSigs []*genMethodSignature
 		for j := range impl.methods {
 			method := &impl.methods[j]
-			b.WriteString("func (h *")
-			b.WriteString(genTypeName)
-			b.WriteString(") ")
-			b.WriteString(method.methodName)
-			b.WriteRune('(')
-
-			hasContext, hasInput := false, false
-			params := make([]string, 0, 2)
-			for x := range method.params {
-				param := &method.params[x]
-
-				if param.typ == "context.Context" {
-					hasContext = true
-					params = append(params, "ctx context.Context")
-				} else {
-					hasInput = true
-					for _, path := range param.pkgPaths {
-						imp := usedImports[path]
-						if imp == nil {
-							continue
-						}
-						param.typ = strings.ReplaceAll(param.typ, imp.name(), packageNameTable[path])
-					}
-					params = append(params, fmt.Sprintf("in %s", param.typ))
-				}
-
-			}
-			b.WriteString(strings.Join(params, ", "))
-			b.WriteString(")")
-
-			hasResult, hasError := false, false
-			results := make([]string, 0, 2)
-			for x := range method.results {
-				result := &method.results[x]
-
-				if result.typ == "error" {
-					hasError = true
-					results = append(results, "err error")
-				} else {
-					hasResult = true
-					for _, path := range result.pkgPaths {
-						imp := usedImports[path]
-						if imp == nil {
-							continue
-						}
-						result.typ = strings.ReplaceAll(result.typ, imp.name(), packageNameTable[path])
-					}
-					results = append(results, fmt.Sprintf("res %s", result.typ))
-				}
-			}
-
-			if len(results) > 0 {
-				b.WriteString(" (")
-				b.WriteString(strings.Join(results, ", "))
-				b.WriteRune(')')
+			sig := makeGenMethodSignature(method, usedImports, packageNameTable)
+			sig.funcKeyName = funcKeyNameTable[pkgPath+impl.typName+method.methodName]
+			sig.schemaHashName = schemaHashNameTable[pkgPath+impl.typName+method.methodName]
+			sig.typed = generics && method.typed
+			sigTable[pkgPath+impl.typName+method.methodName] = sig
+			writeGenHandlerMethod(&b, genTypeName, sig)
+
+			if sig.isErrorOnly() {
+				asyncSigs = append(asyncSigs, sig)
 			}
+		}
 
-			b.WriteString(" {\n\t")
-			if hasError {
-				b.WriteString("err = ")
-			}
-			b.WriteString("h.invoker.\n")
-			b.WriteString("\tFunc(")
-			b.WriteString(funcKeyNameTable[pkgPath+impl.typName+method.methodName])
-			b.WriteString(").\n")
-			b.WriteString("\tInvoke(")
-
-			contextValue := "ctx"
-			if !hasContext {
-				contextValue = "context.Background()"
-			}
+		if len(asyncSigs) == 0 {
+			continue
+		}
 
-			inputValue := "in"
-			if !hasInput {
-				inputValue = "nil"
-			}
+		// methods returning only error also get a fire-and-forget client using "Event" invocation
+		asyncTypeName := fmt.Sprintf("%s%sAsync", pkgPath, impl.typName)
+		genAsyncTypeName := fmt.Sprintf("asyncHandler%s%sImpl", pkgPath, impl.typName)
+		b.WriteString("type ")
+		b.WriteString(asyncTypeName)
+		b.WriteString(" interface {\n")
+		for _, sig := range asyncSigs {
+			b.WriteRune('\t')
+			writeGenMethodSignature(&b, sig)
+			b.WriteRune('\n')
+		}
+		b.WriteString("}\n\n")
 
-			b.WriteString(fmt.Sprintf("%s, %s).\n", contextValue, inputValue))
-			b.WriteString("\tResult(")
+		b.WriteString(fmt.Sprintf("func New%s%sAsyncHandler(cli lamlam.Transport) %s {\n", pkgPath, impl.typName, asyncTypeName))
+		b.WriteString("\tinvoker := lamlam.NewInvoker(cli, LambdaName)\n")
+		b.WriteString("\tinvoker.SetInvocationType(lamlam.InvocationEvent)\n")
+		b.WriteString("\treturn &")
+		b.WriteString(genAsyncTypeName)
+		b.WriteString("{\n")
+		b.WriteString("\t\tinvoker: invoker,\n")
+		b.WriteString("\t}\n")
+		b.WriteString("}\n\n")
 
-			if hasResult {
-				b.WriteString("&res)\n")
-			} else {
-				b.WriteString("nil)\n")
-			}
+		b.WriteString("type ")
+		b.WriteString(genAsyncTypeName)
+		b.WriteString(" struct {\n")
+		b.WriteString("\tinvoker *lamlam.Invoker\n")
+		b.WriteString("}\n\n")
 
-			b.WriteString("\treturn\n")
-			b.WriteString("}\n\n")
+		for _, sig := range asyncSigs {
+			writeGenHandlerMethod(&b, genAsyncTypeName, sig)
 		}
 	}
 
@@ -416,13 +511,38 @@ func gen(pkgs []*packages.Package, lambda *config.Lambda) ([]byte, error) {
 		intface := &mux.interfaces[i]
 		pkgPath := convertUpperCamelCasePkgPath(strings.TrimPrefix(intface.pkgPath, moduleName))
 
-		b.WriteString(fmt.Sprintf("func BindMux%s%s(m *lamlam.Mux, in %s.%s) {\n", pkgPath, intface.typName, packageNameTable[intface.pkgPath], intface.typName))
+		b.WriteString(fmt.Sprintf("func BindMux%s%s(m *lamlam.Mux, in %s.%s, opts ...lamlam.FuncOptions) {\n", pkgPath, intface.typName, packageNameTable[intface.pkgPath], intface.typName))
+		b.WriteString("\tfuncOpts := lamlam.JoinFuncOptions(opts...)\n")
 		for _, method := range intface.methods {
-			b.WriteString("\tm.Set(")
+			// methods of the typed shape register without per-call reflection
+			if typedTable[pkgPath+intface.typName+method] {
+				b.WriteString("\tlamlam.Handle(m, ")
+			} else {
+				b.WriteString("\tm.Set(")
+			}
 			b.WriteString(funcKeyNameTable[pkgPath+intface.typName+method])
 			b.WriteString(", in.")
 			b.WriteString(method)
-			b.WriteString(")\n")
+			// options given for the funcKey come last, so they win over the generated ones
+			b.WriteString(", funcOpts.For(")
+			b.WriteString(funcKeyNameTable[pkgPath+intface.typName+method])
+			b.WriteString(", lamlam.WithSchemaHash(")
+			b.WriteString(schemaHashNameTable[pkgPath+intface.typName+method])
+			b.WriteRune(')')
+			if names := paramNamesTable[pkgPath+intface.typName+method]; len(names) > 0 {
+				quoted := make([]string, 0, len(names))
+				for _, name := range names {
+					quoted = append(quoted, fmt.Sprintf("%q", name))
+				}
+				b.WriteString(", lamlam.WithParamNames(")
+				b.WriteString(strings.Join(quoted, ", "))
+				b.WriteRune(')')
+			}
+			if sig := sigTable[pkgPath+intface.typName+method]; sig != nil && !sig.typed {
+				b.WriteString(", ")
+				writeGenCall(&b, method, sig)
+			}
+			b.WriteString(")...)\n")
 		}
 
 		b.WriteString("}\n\n")
@@ -431,6 +551,294 @@ func gen(pkgs []*packages.Package, lambda *config.Lambda) ([]byte, error) {
 	return format.Source(b.Bytes())
 }
 
+type genMethodSignature struct {
+	methodName     string
+	funcKeyName    string
+	schemaHashName string
+	params         []string
+	results        []string
+	hasContext     bool
+	inputValue     string
+	hasResult      bool
+	outputs        []string
+	callInputs     []genCallInput
+	typed          bool
+	inputType      string
+	outputType     string
+	hasError       bool
+	idempotent     bool
+}
+
+// genCallInput is a data argument as the WithCall option lamlam gen writes decodes it.
+type genCallInput struct {
+	name     string
+	typ      string
+	variadic bool
+}
+
+func (sig *genMethodSignature) isErrorOnly() bool {
+	return sig.hasError && !sig.hasResult
+}
+
+func makeGenMethodSignature(method *genImplementMethod, usedImports map[string]*importData, packageNameTable map[string]string) *genMethodSignature {
+	sig := &genMethodSignature{
+		methodName: method.methodName,
+		idempotent: method.idempotent,
+		params:     make([]string, 0, 2),
+		results:    make([]string, 0, 2),
+	}
+
+	// locals named after the interface must not shadow what the method body uses
+	reserved := map[string]bool{"ctx": true, "h": true, "in": true, "res": true, "err": true, "context": true, "lamlam": true}
+	for _, name := range packageNameTable {
+		reserved[name] = true
+	}
+
+	var inputs []*genImplementMethodValue
+	for x := range method.params {
+		param := &method.params[x]
+
+		if param.typ == "context.Context" {
+			sig.hasContext = true
+			sig.params = append(sig.params, "ctx context.Context")
+		} else {
+			inputs = append(inputs, param)
+		}
+	}
+
+	for _, input := range inputs {
+		sig.callInputs = append(sig.callInputs, genCallInput{
+			name:     input.name,
+			typ:      qualifyGenType(input, usedImports, packageNameTable),
+			variadic: input.variadic,
+		})
+	}
+
+	switch len(inputs) {
+	case 0:
+		sig.inputValue = "nil"
+	case 1:
+		sig.inputValue = "in"
+		sig.inputType = qualifyGenParamType(inputs[0], usedImports, packageNameTable)
+		sig.params = append(sig.params, fmt.Sprintf("in %s", sig.inputType))
+	default:
+		// several inputs travel as an object keyed by parameter name
+		values := make([]string, 0, len(inputs))
+		for x, input := range inputs {
+			local := input.name
+			if reserved[local] {
+				local = fmt.Sprintf("in%d", x)
+			}
+			reserved[local] = true
+
+			sig.params = append(sig.params, fmt.Sprintf("%s %s", local, qualifyGenParamType(input, usedImports, packageNameTable)))
+			values = append(values, fmt.Sprintf("%q: %s", input.name, local))
+		}
+		sig.inputValue = fmt.Sprintf("map[string]interface{}{%s}", strings.Join(values, ", "))
+	}
+
+	numOutputs := 0
+	for x := range method.results {
+		if method.results[x].typ != "error" {
+			numOutputs++
+		}
+	}
+
+	for x := range method.results {
+		result := &method.results[x]
+
+		if result.typ == "error" {
+			sig.hasError = true
+			sig.results = append(sig.results, "err error")
+			continue
+		}
+
+		sig.hasResult = true
+		local := "res"
+		if numOutputs > 1 {
+			// several outputs travel as a tuple, each named result gets its element
+			local = result.name
+			if local == "" || local == "_" || reserved[local] {
+				local = fmt.Sprintf("res%d", len(sig.outputs))
+			}
+			reserved[local] = true
+		}
+
+		sig.outputType = qualifyGenType(result, usedImports, packageNameTable)
+		sig.outputs = append(sig.outputs, "&"+local)
+		sig.results = append(sig.results, fmt.Sprintf("%s %s", local, sig.outputType))
+	}
+
+	return sig
+}
+
+func qualifyGenParamType(value *genImplementMethodValue, usedImports map[string]*importData, packageNameTable map[string]string) string {
+	typ := qualifyGenType(value, usedImports, packageNameTable)
+	if value.variadic {
+		return "..." + typ
+	}
+
+	return typ
+}
+
+func qualifyGenType(value *genImplementMethodValue, usedImports map[string]*importData, packageNameTable map[string]string) string {
+	typ := value.typ
+	for _, path := range value.pkgPaths {
+		imp := usedImports[path]
+		if imp == nil {
+			continue
+		}
+		typ = strings.ReplaceAll(typ, imp.name(), packageNameTable[path])
+	}
+
+	return typ
+}
+
+func writeGenMethodSignature(b *bytes.Buffer, sig *genMethodSignature) {
+	b.WriteString(sig.methodName)
+	b.WriteRune('(')
+	b.WriteString(strings.Join(sig.params, ", "))
+	b.WriteString(")")
+
+	if len(sig.results) > 0 {
+		b.WriteString(" (")
+		b.WriteString(strings.Join(sig.results, ", "))
+		b.WriteRune(')')
+	}
+}
+
+func writeGenHandlerMethod(b *bytes.Buffer, genTypeName string, sig *genMethodSignature) {
+	b.WriteString("func (h *")
+	b.WriteString(genTypeName)
+	b.WriteString(") ")
+	writeGenMethodSignature(b, sig)
+
+	b.WriteString(" {\n\t")
+	if sig.typed {
+		writeGenTypedCall(b, sig)
+		return
+	}
+
+	if sig.hasError {
+		b.WriteString("err = ")
+	}
+	b.WriteString("h.invoker.\n")
+	b.WriteString("\tFunc(")
+	b.WriteString(sig.funcKeyName)
+	b.WriteString(").\n")
+	b.WriteString("\tSchemaHash(")
+	b.WriteString(sig.schemaHashName)
+	b.WriteString(").\n")
+	if sig.idempotent {
+		b.WriteString("\tIdempotent().\n")
+	}
+	b.WriteString("\tInvoke(")
+
+	contextValue := "ctx"
+	if !sig.hasContext {
+		contextValue = "context.Background()"
+	}
+
+	b.WriteString(fmt.Sprintf("%s, %s).\n", contextValue, sig.inputValue))
+
+	switch len(sig.outputs) {
+	case 0:
+		b.WriteString("\tResult(nil)\n")
+	case 1:
+		b.WriteString(fmt.Sprintf("\tResult(%s)\n", sig.outputs[0]))
+	default:
+		b.WriteString(fmt.Sprintf("\tResults(%s)\n", strings.Join(sig.outputs, ", ")))
+	}
+
+	b.WriteString("\treturn\n")
+	b.WriteString("}\n\n")
+}
+
+// writeGenCall writes the WithCall option calling method of the service in, so Mux needs no reflection per call.
+func writeGenCall(b *bytes.Buffer, method string, sig *genMethodSignature) {
+	b.WriteString("lamlam.WithCall(func(ctx context.Context, p *lamlam.Payload) (interface{}, error) {\n")
+
+	args := make([]string, 0, len(sig.callInputs)+1)
+	if sig.hasContext {
+		args = append(args, "ctx")
+	}
+
+	switch len(sig.callInputs) {
+	case 0:
+	case 1:
+		input := &sig.callInputs[0]
+		typ := input.typ
+		if input.variadic {
+			typ = "[]" + typ
+		}
+		b.WriteString(fmt.Sprintf("var arg %s\n", typ))
+		b.WriteString("if err := p.Bind(&arg); err != nil {\nreturn nil, err\n}\n")
+		args = append(args, "arg")
+	default:
+		// BindParams fails on missing or unexpected names, like Mux does without WithCall
+		names := make([]string, 0, len(sig.callInputs))
+		dsts := make([]string, 0, len(sig.callInputs))
+		b.WriteString("var args struct {\n")
+		for x := range sig.callInputs {
+			input := &sig.callInputs[x]
+			typ := input.typ
+			if input.variadic {
+				typ = "[]" + typ
+			}
+			b.WriteString(fmt.Sprintf("Arg%d %s\n", x, typ))
+			args = append(args, fmt.Sprintf("args.Arg%d", x))
+			names = append(names, fmt.Sprintf("%q", input.name))
+			dsts = append(dsts, fmt.Sprintf("&args.Arg%d", x))
+		}
+		b.WriteString("}\n")
+		b.WriteString(fmt.Sprintf("if err := p.BindParams([]string{%s}, %s); err != nil {\nreturn nil, err\n}\n", strings.Join(names, ", "), strings.Join(dsts, ", ")))
+	}
+
+	if n := len(sig.callInputs); n > 0 && sig.callInputs[n-1].variadic {
+		args[len(args)-1] += "..."
+	}
+	call := fmt.Sprintf("in.%s(%s)", method, strings.Join(args, ", "))
+
+	switch n := len(sig.outputs); {
+	case n == 0 && !sig.hasError:
+		b.WriteString(call + "\nreturn nil, nil\n")
+	case n == 0:
+		b.WriteString("return nil, " + call + "\n")
+	case n == 1 && !sig.hasError:
+		b.WriteString("return " + call + ", nil\n")
+	case n == 1:
+		b.WriteString("return " + call + "\n")
+	default:
+		// several results travel as a tuple, like Mux encodes them
+		results := make([]string, n)
+		for x := range results {
+			results[x] = fmt.Sprintf("r%d", x)
+		}
+		if sig.hasError {
+			b.WriteString(fmt.Sprintf("%s, err := %s\n", strings.Join(results, ", "), call))
+			b.WriteString("if err != nil {\nreturn nil, err\n}\n")
+		} else {
+			b.WriteString(fmt.Sprintf("%s := %s\n", strings.Join(results, ", "), call))
+		}
+		b.WriteString(fmt.Sprintf("return []interface{}{%s}, nil\n", strings.Join(results, ", ")))
+	}
+
+	b.WriteString("})")
+}
+
+// writeGenTypedCall writes the body of a method calling through lamlam.Call.
+func writeGenTypedCall(b *bytes.Buffer, sig *genMethodSignature) {
+	b.WriteString(fmt.Sprintf("return lamlam.Call[%s, %s](\n", sig.inputType, sig.outputType))
+	b.WriteString("\th.invoker,\n")
+	b.WriteString(fmt.Sprintf("\t%s,\n", sig.funcKeyName))
+	b.WriteString(fmt.Sprintf("\tlamlam.CallSchemaHash(%s),\n", sig.schemaHashName))
+	if sig.idempotent {
+		b.WriteString("\tlamlam.CallIdempotent(),\n")
+	}
+	b.WriteString(")(ctx, in)\n")
+	b.WriteString("}\n\n")
+}
+
 func makeGenFuncKeys(interfaces []interfaceData) *genFuncKeys {
 	var keys []genFuncKeyPair
 	for i := range interfaces {
@@ -443,6 +851,9 @@ func makeGenFuncKeys(interfaces []interfaceData) *genFuncKeys {
 				pkgPath:       intface.pkg.PkgPath,
 				interfaceName: intface.name(),
 				methodName:    method.name(),
+				schemaHash:    method.schemaHash(),
+				paramNames:    method.dataParamNames(),
+				typed:         method.typed(),
 			})
 
 		}
@@ -483,28 +894,43 @@ func makeGenHandler(interfaces []interfaceData) *genHandler {
 		methods := make([]genImplementMethod, 0, len(intface.methods))
 		for j := range intface.methods {
 			method := &intface.methods[j]
-			params := make([]genImplementMethodValue, 0, len(method.params))
-			for _, param := range method.params {
-				pt := pkg.TypesInfo.TypeOf(param.Type)
+			methodParams := method.methodParams()
+			params := make([]genImplementMethodValue, 0, len(methodParams))
+			for _, param := range methodParams {
 				params = append(params, genImplementMethodValue{
-					pkgPaths: getTypePkgPaths(pt),
-					typ:      getTypeName(pt),
+					pkgPaths: getTypePkgPaths(param.typ),
+					typ:      getTypeName(param.typ),
+					name:     param.name,
+					variadic: param.variadic,
 				})
 			}
 
 			results := make([]genImplementMethodValue, 0, len(method.results))
 			for _, result := range method.results {
 				rt := pkg.TypesInfo.TypeOf(result.Type)
-				results = append(results, genImplementMethodValue{
-					pkgPaths: getTypePkgPaths(rt),
-					typ:      getTypeName(rt),
-				})
+				names := []string{""}
+				if len(result.Names) > 0 {
+					names = names[:0]
+					for _, name := range result.Names {
+						names = append(names, name.Name)
+					}
+				}
+
+				for _, name := range names {
+					results = append(results, genImplementMethodValue{
+						pkgPaths: getTypePkgPaths(rt),
+						typ:      getTypeName(rt),
+						name:     name,
+					})
+				}
 			}
 
 			methods = append(methods, genImplementMethod{
 				methodName: method.name(),
 				params:     params,
 				results:    results,
+				idempotent: method.idempotent(),
+				typed:      method.typed(),
 			})
 		}
 
@@ -0,0 +1,156 @@
+package lamlam
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+// schemaHashLength is the number of hex digits kept from the sha256 of a method schema.
+const schemaHashLength = 16
+
+// schemaHash hashes the JSON shape of the input and output types of the method,
+// so renaming a type keeps the hash while changing a field name or type does not.
+func (m methodData) schemaHash() string {
+	var b strings.Builder
+	// names key the data only when there are several parameters
+	keyed := len(m.dataParamNames()) > 0
+
+	b.WriteString("in(")
+	first := true
+	for _, param := range m.methodParams() {
+		if isContextType(param.typ) {
+			continue
+		}
+		if !first {
+			b.WriteRune(',')
+		}
+		first = false
+
+		if keyed {
+			b.WriteString(param.name + ":")
+		}
+		if param.variadic {
+			b.WriteString("...")
+		}
+		writeSchemaType(&b, param.typ, make(map[*types.Named]bool))
+	}
+
+	b.WriteString(")out(")
+	first = true
+	for _, field := range m.results {
+		typ := m.pkg.TypesInfo.TypeOf(field.Type)
+		if isErrorType(typ) {
+			continue
+		}
+
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			if !first {
+				b.WriteRune(',')
+			}
+			first = false
+			writeSchemaType(&b, typ, make(map[*types.Named]bool))
+		}
+	}
+	b.WriteString(")")
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])[:schemaHashLength]
+}
+
+func isContextType(typ types.Type) bool {
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj() == nil || named.Obj().Pkg() == nil {
+		return false
+	}
+
+	return named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
+
+func isErrorType(typ types.Type) bool {
+	named, ok := typ.(*types.Named)
+	return ok && named.Obj() != nil && named.Obj().Pkg() == nil && named.Obj().Name() == "error"
+}
+
+func writeSchemaType(b *strings.Builder, typ types.Type, visiting map[*types.Named]bool) {
+	switch typ := typ.(type) {
+	case *types.Basic:
+		b.WriteString(typ.Name())
+	case *types.Pointer:
+		writeSchemaType(b, typ.Elem(), visiting)
+	case *types.Slice:
+		b.WriteString("[]")
+		writeSchemaType(b, typ.Elem(), visiting)
+	case *types.Array:
+		b.WriteString(fmt.Sprintf("[%d]", typ.Len()))
+		writeSchemaType(b, typ.Elem(), visiting)
+	case *types.Map:
+		b.WriteString("map[")
+		writeSchemaType(b, typ.Key(), visiting)
+		b.WriteRune(']')
+		writeSchemaType(b, typ.Elem(), visiting)
+	case *types.Interface:
+		b.WriteString("any")
+	case *types.Named:
+		obj := typ.Obj()
+		st, isStruct := typ.Underlying().(*types.Struct)
+		switch {
+		case visiting[typ]:
+			// recursive types refer back by name
+			b.WriteString(obj.Name())
+			return
+		case isStruct && obj.Pkg() != nil && !hasExportedField(st):
+			// types like time.Time encode themselves, only their name tells the shape
+			b.WriteString(obj.Pkg().Path() + "." + obj.Name())
+			return
+		}
+
+		visiting[typ] = true
+		writeSchemaType(b, typ.Underlying(), visiting)
+		delete(visiting, typ)
+	case *types.Struct:
+		b.WriteString("{")
+		for i := 0; i < typ.NumFields(); i++ {
+			field := typ.Field(i)
+			tag := reflect.StructTag(typ.Tag(i)).Get("json")
+			if tag == "-" || (!field.Exported() && !field.Embedded()) {
+				continue
+			}
+
+			// fields go by their json name, embedded structs without one are promoted
+			name, opts := tag, ""
+			if i := strings.IndexByte(tag, ','); i >= 0 {
+				name, opts = tag[:i], tag[i:]
+			}
+			if name == "" && field.Embedded() {
+				name = "..."
+			} else if name == "" {
+				name = field.Name()
+			}
+
+			b.WriteString(name + opts + ":")
+			writeSchemaType(b, field.Type(), visiting)
+			b.WriteRune(';')
+		}
+		b.WriteString("}")
+	default:
+		b.WriteString(typ.String())
+	}
+}
+
+func hasExportedField(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Exported() {
+			return true
+		}
+	}
+
+	return false
+}
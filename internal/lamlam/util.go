@@ -5,12 +5,14 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 )
 
 const (
-	buildTag = "lamlam"
+	buildTag            = "lamlam"
+	idempotentDirective = "//lamlam:idempotent"
 )
 
 func convertUpperCamelCasePkgPath(pkgPath string) string {
@@ -20,6 +22,59 @@ func convertUpperCamelCasePkgPath(pkgPath string) string {
 	return strings.ReplaceAll(pkgPath, "/", "")
 }
 
+var (
+	_goVersionOnce sync.Once
+	_goVersion     string
+	_goVersionErr  error
+)
+
+// getCurrentModuleGoVersion returns the go directive of go.mod, empty if there is none.
+func getCurrentModuleGoVersion() (string, error) {
+	_goVersionOnce.Do(func() {
+		file, err := os.Open("go.mod")
+		if err != nil {
+			_goVersionErr = err
+			return
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 && fields[0] == "go" {
+				_goVersion = fields[1]
+				return
+			}
+		}
+		_goVersionErr = scanner.Err()
+	})
+	return _goVersion, _goVersionErr
+}
+
+// supportsGenerics reports whether code for go version may use type parameters.
+func supportsGenerics(version string) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	// prereleases like 1.21rc1 count as their release
+	digits := parts[1]
+	if i := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		digits = digits[:i]
+	}
+	minor, err := strconv.Atoi(digits)
+	if err != nil {
+		return false
+	}
+
+	return major > 1 || (major == 1 && minor >= 18)
+}
+
 var (
 	_moduleNameOnce sync.Once
 	_moduleName     string
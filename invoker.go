@@ -3,20 +3,62 @@ package lamlam
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"sync"
+)
+
+type InvocationType = types.InvocationType
+
+const (
+	// InvocationRequestResponse waits for the result, the default.
+	InvocationRequestResponse = types.InvocationTypeRequestResponse
+	// InvocationEvent queues the call and returns without a result.
+	InvocationEvent = types.InvocationTypeEvent
+	// InvocationDryRun only checks the function exists and the caller may invoke it.
+	InvocationDryRun = types.InvocationTypeDryRun
 )
 
 type (
 	Invoker struct {
-		funcName string
-		cli      *lambda.Client
+		funcName     string
+		transport    Transport
+		lock         sync.RWMutex
+		interceptors []Interceptor
+		handlerOptions
 	}
 
-	invokeFunc func(context.Context, []byte) ([]byte, error)
+	// handlerOptions are set on the Invoker and copied into every Handler it creates.
+	handlerOptions struct {
+		invocationType    InvocationType
+		compressThreshold int
+		blobStore         BlobStore
+		blobLimit         int
+		retryPolicy       RetryPolicy
+		idempotent        bool
+		circuitBreaker    *CircuitBreaker
+	}
+
+	// Request is an outgoing call, Payload is the encoded envelope sent to the lambda.
+	Request struct {
+		FuncKey        string
+		Payload        []byte
+		InvocationType InvocationType
+	}
+
+	// InvokeFunc sends req and returns the raw response.
+	InvokeFunc func(ctx context.Context, req *Request) ([]byte, error)
+
+	// Interceptor wraps an InvokeFunc, the first registered interceptor runs outermost.
+	Interceptor func(next InvokeFunc) InvokeFunc
 
 	Handler struct {
-		invoke  invokeFunc
-		payload payloadType
+		funcName     string
+		invoke       InvokeFunc
+		interceptors []Interceptor
+		payload      payloadType
+		handlerOptions
 	}
 
 	Return struct {
@@ -25,29 +67,57 @@ type (
 	}
 )
 
-func NewInvoker(cli *lambda.Client, funcName string) *Invoker {
+func NewInvoker(transport Transport, funcName string) *Invoker {
 	return &Invoker{
-		funcName: funcName,
-		cli:      cli,
+		funcName:  funcName,
+		transport: transport,
 	}
 }
 
+// Client returns the underlying *lambda.Client, nil if the transport is something else.
 func (i *Invoker) Client() *lambda.Client {
-	return i.cli
+	cli, _ := i.transport.(*lambda.Client)
+	return cli
+}
+
+func (i *Invoker) Transport() Transport {
+	return i.transport
+}
+
+// Use appends interceptors that run for every call made through i.
+func (i *Invoker) Use(interceptors ...Interceptor) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.interceptors = append(i.interceptors, interceptors...)
+}
+
+// SetInvocationType sets the invocation type of handlers created by Func afterwards.
+func (i *Invoker) SetInvocationType(invocationType InvocationType) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.invocationType = invocationType
 }
 
 func (i *Invoker) Func(key string) *Handler {
-	return newInvokeHandler(key, i.invoke)
+	i.lock.RLock()
+	interceptors := i.interceptors[:len(i.interceptors):len(i.interceptors)]
+	options := i.handlerOptions
+	i.lock.RUnlock()
+
+	h := newInvokeHandler(key, i.invoke, interceptors, options)
+	h.funcName = i.funcName
+	return h
 }
 
 func (i *Invoker) Invoke(ctx context.Context, key string, in interface{}) *Return {
 	return i.Func(key).Invoke(ctx, in)
 }
 
-func (i *Invoker) invoke(ctx context.Context, payload []byte) (res []byte, err error) {
-	result, err := i.cli.Invoke(ctx, &lambda.InvokeInput{
-		FunctionName: &i.funcName,
-		Payload:      payload,
+func (i *Invoker) invoke(ctx context.Context, req *Request) (res []byte, err error) {
+	result, err := i.transport.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName:   &i.funcName,
+		InvocationType: req.InvocationType,
+		Payload:        req.Payload,
 	})
 	if err != nil {
 		return
@@ -61,13 +131,33 @@ func (i *Invoker) invoke(ctx context.Context, payload []byte) (res []byte, err e
 	return
 }
 
-func newInvokeHandler(funcKey string, invoke invokeFunc) *Handler {
-	return &Handler{
-		invoke: invoke,
+func newInvokeHandler(funcKey string, invoke InvokeFunc, interceptors []Interceptor, options handlerOptions) *Handler {
+	h := &Handler{
+		invoke:         invoke,
+		interceptors:   interceptors,
+		handlerOptions: options,
 		payload: payloadType{
-			FuncKey: funcKey,
+			FuncKey:        funcKey,
+			AcceptEncoding: encodingGzip,
+			AcceptBlob:     options.blobStore != nil,
 		},
 	}
+
+	return h.InvocationType(options.invocationType)
+}
+
+// Use appends interceptors that run only for this handler, inside the ones of the Invoker.
+func (i *Handler) Use(interceptors ...Interceptor) *Handler {
+	i.interceptors = append(i.interceptors, interceptors...)
+	return i
+}
+
+// InvocationType sets how the call is made, an empty type means InvocationRequestResponse.
+// Event calls are not wrapped, so handler errors stay function errors and lambda retries them.
+func (i *Handler) InvocationType(invocationType InvocationType) *Handler {
+	i.invocationType = invocationType
+	i.payload.Wrap = invocationType != InvocationEvent
+	return i
 }
 
 func (i *Handler) Invoke(ctx context.Context, in any) *Return {
@@ -78,14 +168,86 @@ func (i *Handler) Invoke(ctx context.Context, in any) *Return {
 		return res
 	}
 
-	data, err := json.Marshal(i.payload)
+	res.data, res.err = i.withRetry(ctx, func() ([]byte, error) {
+		return i.receive(ctx)
+	})
+	return res
+}
+
+// receive sends the payload once and unwraps the result.
+func (i *Handler) receive(ctx context.Context) ([]byte, error) {
+	data, err := i.withCircuit(func() ([]byte, error) {
+		return i.send(ctx)
+	})
+	if err != nil || !i.payload.Wrap || len(data) == 0 {
+		return data, err
+	}
+
+	return unwrapResult(ctx, data, i.blobStore)
+}
+
+func (i *Handler) send(ctx context.Context) ([]byte, error) {
+	payload, err := i.encodePayload(ctx)
 	if err != nil {
-		res.err = err
-		return res
+		return nil, err
 	}
 
-	res.data, res.err = i.invoke(ctx, data)
-	return res
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	invoke := chainInterceptors(i.invoke, i.interceptors)
+	return invoke(ctx, &Request{
+		FuncKey:        i.payload.FuncKey,
+		Payload:        data,
+		InvocationType: i.invocationType,
+	})
+}
+
+// encodePayload returns a copy of the payload ready to send, leaving i.payload intact for reuse.
+func (i *Handler) encodePayload(ctx context.Context) (*payloadType, error) {
+	payload := i.payload
+	payload.Metadata = OutgoingMetadata(ctx)
+	if payload.IdempotencyKey == "" {
+		payload.IdempotencyKey = idempotencyKeyFrom(ctx)
+	}
+	// events run after the caller is gone, its deadline would only get them rejected
+	if deadline, ok := ctx.Deadline(); ok && i.invocationType != InvocationEvent {
+		payload.Deadline = deadline.UnixMilli()
+	}
+	if err := i.encodeData(ctx, &payload); err != nil {
+		return nil, err
+	}
+
+	if len(i.payload.Batch) > 0 {
+		payload.Batch = make([]payloadType, len(i.payload.Batch))
+		for x := range i.payload.Batch {
+			payload.Batch[x] = i.payload.Batch[x]
+			if err := i.encodeData(ctx, &payload.Batch[x]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &payload, nil
+}
+
+// encodeData compresses the data of p, then offloads it to the blob store when still too large.
+func (i *Handler) encodeData(ctx context.Context, p *payloadType) error {
+	if err := p.compress(i.compressThreshold); err != nil {
+		return err
+	}
+
+	return p.offload(ctx, i.blobStore, i.blobLimit)
+}
+
+func chainInterceptors(next InvokeFunc, interceptors []Interceptor) InvokeFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		next = interceptors[i](next)
+	}
+
+	return next
 }
 
 func (r *Return) Raw() ([]byte, error) {
@@ -110,9 +272,33 @@ func (r *Return) Result(dst any) error {
 		return r.err
 	}
 
-	if dst == nil {
+	if dst == nil || len(r.data) == 0 {
 		return nil
 	}
 
 	return json.Unmarshal(r.data, dst)
 }
+
+// Results decodes the returns of a function returning several values into dsts in order, nil dsts are skipped.
+func (r *Return) Results(dsts ...any) error {
+	var tuple []json.RawMessage
+	if err := r.Result(&tuple); err != nil {
+		return err
+	}
+
+	if len(tuple) > 0 && len(tuple) != len(dsts) {
+		return errors.New("results count mismatch")
+	}
+
+	for i := range tuple {
+		if dsts[i] == nil {
+			continue
+		}
+
+		if err := json.Unmarshal(tuple[i], dsts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
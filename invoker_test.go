@@ -0,0 +1,82 @@
+package lamlam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"testing"
+)
+
+// legacyMux answers like a Mux older than the result envelope, which ignores Wrap.
+type legacyMux struct {
+	result string
+	err    *ErrorPayload
+}
+
+func (l *legacyMux) Invoke(_ context.Context, _ *lambda.InvokeInput, _ ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	if l.err != nil {
+		return functionErrorOutput(l.err)
+	}
+
+	return &lambda.InvokeOutput{StatusCode: 200, Payload: []byte(l.result)}, nil
+}
+
+type legacyUser struct {
+	Name string `json:"name"`
+}
+
+func TestInvokerLegacyMux(t *testing.T) {
+	ctx := context.Background()
+
+	var user legacyUser
+	err := NewInvoker(&legacyMux{result: `{"name":"alice"}`}, "f").Invoke(ctx, "k", nil).Result(&user)
+	if err != nil || user.Name != "alice" {
+		t.Fatalf("object result: %+v, %v", user, err)
+	}
+
+	var s string
+	err = NewInvoker(&legacyMux{result: `"hello"`}, "f").Invoke(ctx, "k", nil).Result(&s)
+	if err != nil || s != "hello" {
+		t.Fatalf("scalar result: %q, %v", s, err)
+	}
+
+	var n int
+	err = NewInvoker(&legacyMux{result: `null`}, "f").Invoke(ctx, "k", nil).Result(&n)
+	if err != nil || n != 0 {
+		t.Fatalf("null result: %d, %v", n, err)
+	}
+
+	err = NewInvoker(&legacyMux{err: &ErrorPayload{ErrorType: "errNotFoundFunction", ErrorMessage: "not found function"}}, "f").
+		Invoke(ctx, "k", nil).Result(nil)
+	if !errors.Is(err, ErrNotFoundFunction) {
+		t.Fatalf("function error: %v", err)
+	}
+}
+
+func TestMuxLegacyCaller(t *testing.T) {
+	m := NewMux()
+	if err := m.Set("k", func(in legacyUser) (legacyUser, error) { return in, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set("fail", func() error { return ErrInProgress }); err != nil {
+		t.Fatal(err)
+	}
+
+	// callers older than the envelope send neither wrap nor any other new field
+	res, err := m.Invoke(context.Background(), []byte(`{"funcKey":"k","data":{"name":"bob"}}`))
+	if err != nil || string(res) != `{"name":"bob"}` {
+		t.Fatalf("raw result: %s, %v", res, err)
+	}
+
+	_, err = m.Invoke(context.Background(), []byte(`{"funcKey":"fail","data":null}`))
+	if err != ErrInProgress {
+		t.Fatalf("raw error: %v", err)
+	}
+
+	ep := newRuntimeErrorPayload(err)
+	data, _ := json.Marshal(ep)
+	if string(data) != `{"errorMessage":"call with the same idempotency key in progress","errorType":"errInProgress"}` {
+		t.Fatalf("runtime error payload: %s", data)
+	}
+}
@@ -0,0 +1,96 @@
+package lamlam
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// tokenBucket refills rate tokens per second up to burst, each call takes one.
+	tokenBucket struct {
+		lock   sync.Mutex
+		rate   float64
+		burst  float64
+		tokens float64
+		last   time.Time
+	}
+
+	errLimitExceeded struct{}
+)
+
+func (err *errLimitExceeded) Error() string {
+	return "limit exceeded"
+}
+
+// WithConcurrencyLimit rejects calls while n calls of the funcKey are running.
+func WithConcurrencyLimit(n int) SetOption {
+	return func(h *handler) {
+		if n > 0 {
+			h.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithRateLimit rejects calls of the funcKey beyond rps per second, allowing bursts of burst calls.
+func WithRateLimit(rps float64, burst int) SetOption {
+	return func(h *handler) {
+		if rps <= 0 {
+			return
+		}
+		if burst < 1 {
+			burst = 1
+		}
+
+		h.limiter = &tokenBucket{
+			rate:   rps,
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   time.Now(),
+		}
+	}
+}
+
+func (b *tokenBucket) take() bool {
+	now := time.Now()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// limit runs next within the limits of h, rejecting the call with ErrLimitExceeded.
+func (h *handler) limit(next DispatchFunc) DispatchFunc {
+	if h.sem == nil && h.limiter == nil {
+		return next
+	}
+
+	return func(ctx context.Context, funcKey string, p *payloadType) ([]byte, error) {
+		if h.limiter != nil && !h.limiter.take() {
+			return nil, ErrLimitExceeded
+		}
+
+		if h.sem != nil {
+			select {
+			case h.sem <- struct{}{}:
+				defer func() { <-h.sem }()
+			default:
+				return nil, ErrLimitExceeded
+			}
+		}
+
+		return next(ctx, funcKey, p)
+	}
+}
@@ -0,0 +1,72 @@
+package lamlam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"reflect"
+)
+
+const functionErrorUnhandled = "Unhandled"
+
+type loopback struct {
+	mux *Mux
+}
+
+var _ Transport = (*loopback)(nil)
+
+// NewLoopback returns a Transport dispatching straight into m in the same process.
+// Payloads and errors are encoded the same way the lambda runtime does.
+func NewLoopback(m *Mux) Transport {
+	return &loopback{mux: m}
+}
+
+func (l *loopback) Invoke(ctx context.Context, params *lambda.InvokeInput, _ ...func(*lambda.Options)) (out *lambda.InvokeOutput, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			out, err = functionErrorOutput(&ErrorPayload{
+				ErrorMessage: fmt.Sprint(v),
+				ErrorType:    getTypeName(reflect.TypeOf(v)),
+			})
+		}
+	}()
+
+	switch params.InvocationType {
+	case InvocationDryRun:
+		return &lambda.InvokeOutput{StatusCode: 204}, nil
+	case InvocationEvent:
+		// like lambda the event outlives the caller, its result and error are dropped
+		go func() {
+			defer func() {
+				recover()
+			}()
+			_, _ = l.mux.Invoke(context.Background(), params.Payload)
+		}()
+		return &lambda.InvokeOutput{StatusCode: 202}, nil
+	}
+
+	res, err := l.mux.Invoke(ctx, params.Payload)
+	if err != nil {
+		return functionErrorOutput(newRuntimeErrorPayload(err))
+	}
+
+	return &lambda.InvokeOutput{
+		StatusCode: 200,
+		Payload:    res,
+	}, nil
+}
+
+func functionErrorOutput(ep *ErrorPayload) (*lambda.InvokeOutput, error) {
+	payload, err := json.Marshal(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	functionError := functionErrorUnhandled
+	return &lambda.InvokeOutput{
+		StatusCode:    200,
+		Payload:       payload,
+		FunctionError: &functionError,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package lamlam
+
+import "context"
+
+// Metadata is carried in the envelope next to the data, such as caller name, correlation or tenant id.
+type Metadata map[string]string
+
+type (
+	outgoingMetadataKey struct{}
+	incomingMetadataKey struct{}
+)
+
+func (md Metadata) Copy() Metadata {
+	if md == nil {
+		return nil
+	}
+
+	res := make(Metadata, len(md))
+	for key, value := range md {
+		res[key] = value
+	}
+
+	return res
+}
+
+// WithOutgoingMetadata returns a copy of ctx whose calls send md, merged over metadata already set on ctx.
+func WithOutgoingMetadata(ctx context.Context, md Metadata) context.Context {
+	merged := OutgoingMetadata(ctx)
+	if merged == nil {
+		merged = make(Metadata, len(md))
+	}
+
+	for key, value := range md {
+		merged[key] = value
+	}
+
+	return context.WithValue(ctx, outgoingMetadataKey{}, merged)
+}
+
+// OutgoingMetadata returns a copy of the metadata calls made with ctx send.
+func OutgoingMetadata(ctx context.Context) Metadata {
+	md, _ := ctx.Value(outgoingMetadataKey{}).(Metadata)
+	return md.Copy()
+}
+
+// IncomingMetadata returns a copy of the metadata the caller sent, for use inside handlers of Mux.
+func IncomingMetadata(ctx context.Context) Metadata {
+	md, _ := ctx.Value(incomingMetadataKey{}).(Metadata)
+	return md.Copy()
+}
+
+func withIncomingMetadata(ctx context.Context, md Metadata) context.Context {
+	if len(md) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, incomingMetadataKey{}, md)
+}
@@ -4,11 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-lambda-go/lambda"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// reservedFuncKeyPrefix starts the funcKeys Mux answers itself, such as ping, describe and batch.
+const reservedFuncKeyPrefix = "__lamlam."
+
 type _inputKind int
 
 const (
@@ -16,6 +23,7 @@ const (
 	inputContextOnly
 	inputDataOnly
 	inputBoth
+	inputParams
 )
 
 type _outputKind int
@@ -25,6 +33,7 @@ const (
 	outputErrorOnly
 	outputDataOnly
 	outputBoth
+	outputResults
 )
 
 var (
@@ -41,49 +50,146 @@ type (
 		outputKind     _outputKind
 		funcValue      reflect.Value
 		funcType       reflect.Type
-		funcInputType  [2]reflect.Type
-		funcOutputType [2]reflect.Type
+		funcInputType  []reflect.Type
+		withContext    bool
+		paramNames     []string
+		funcOutputType []reflect.Type
+		withError      bool
+		sem            chan struct{}
+		limiter        *tokenBucket
+		schemaHash     string
+		call           CallFunc
 	}
 
 	Mux struct {
-		tableLock sync.RWMutex
-		funcTable map[string]*handler
+		tableLock sync.Mutex   // serializes changes, calls never take it
+		table     atomic.Value // *muxTable
+	}
+
+	// muxTable is a snapshot of the Mux, never changed once stored, so calls read it without locking.
+	// Changes store a new snapshot, compiled again.
+	muxTable struct {
+		funcTable          map[string]*handler
+		middlewares        []Middleware
+		funcMiddlewares    map[string][]Middleware
+		panicHook          PanicHook
+		batchConcurrency   int
+		compressThreshold  int
+		blobStore          BlobStore
+		blobLimit          int
+		dedupeStore        DedupeStore
+		schemaCheck        SchemaCheck
+		schemaMismatchHook SchemaMismatchHook
+
+		// compiled from the fields above
+		root  DispatchFunc
+		funcs map[string]DispatchFunc
 	}
+
+	// DispatchFunc handles a decoded payload addressed to funcKey.
+	DispatchFunc func(ctx context.Context, funcKey string, payload *Payload) ([]byte, error)
+
+	// Middleware wraps a DispatchFunc, the first registered middleware runs outermost.
+	Middleware func(next DispatchFunc) DispatchFunc
+
+	// SetOption configures a handler registered by Mux.Set.
+	SetOption func(h *handler)
+
+	// FuncOptions are SetOptions keyed by funcKey, such as limits given to the BindMux functions lamlam gen writes.
+	FuncOptions map[string][]SetOption
+
+	// CallFunc calls a registered function with its arguments decoded from payload,
+	// returning the value to encode as its result.
+	CallFunc func(ctx context.Context, payload *Payload) (any, error)
 )
 
 func NewMux() *Mux {
-	return &Mux{
-		funcTable: make(map[string]*handler),
-	}
-}
-
-//
-//func MakeMux[T any](t T) (m *Mux) {
-//	m = NewMux()
-//
-//	val := reflect.ValueOf(t)
-//	if !val.IsValid() || val.IsNil() {
-//		return
-//	}
-//
-//	val.Type()
-//	typ := reflect.TypeOf(new(T)).Elem()
-//	name := typ.Name()
-//	for i := 0; i < typ.NumMethod(); i++ {
-//		methodTyp := typ.Method(i)
-//		if !methodTyp.IsExported() {
-//			continue
-//		}
-//
-//		funcValue := val.MethodByName(methodTyp.Name)
-//		m.setHandler(fmt.Sprintf("%s_%s", name, methodTyp.Name), &handler{
-//			funcValue: funcValue,
-//			funcType:  funcValue.Type(),
-//		})
-//	}
-//
-//	return
-//}
+	t := &muxTable{
+		funcTable:       make(map[string]*handler),
+		funcMiddlewares: make(map[string][]Middleware),
+	}
+	t.compile()
+
+	m := &Mux{}
+	m.table.Store(t)
+	return m
+}
+
+func (m *Mux) load() *muxTable {
+	return m.table.Load().(*muxTable)
+}
+
+// update applies change to a copy of the current snapshot, then compiles and stores it.
+func (m *Mux) update(change func(t *muxTable)) {
+	m.tableLock.Lock()
+	defer m.tableLock.Unlock()
+
+	t := m.load().clone()
+	change(t)
+	t.compile()
+	m.table.Store(t)
+}
+
+func (t *muxTable) clone() *muxTable {
+	c := *t
+	c.middlewares = t.middlewares[:len(t.middlewares):len(t.middlewares)]
+
+	c.funcTable = make(map[string]*handler, len(t.funcTable))
+	for funcKey, h := range t.funcTable {
+		c.funcTable[funcKey] = h
+	}
+
+	c.funcMiddlewares = make(map[string][]Middleware, len(t.funcMiddlewares))
+	for funcKey, mws := range t.funcMiddlewares {
+		c.funcMiddlewares[funcKey] = mws[:len(mws):len(mws)]
+	}
+
+	return &c
+}
+
+// compile chains the middlewares around every handler ahead of the calls.
+func (t *muxTable) compile() {
+	t.funcs = make(map[string]DispatchFunc, len(t.funcTable))
+	for funcKey, h := range t.funcTable {
+		t.funcs[funcKey] = t.compileFunc(funcKey, h)
+	}
+
+	t.root = chainMiddlewares(t.call, t.middlewares)
+}
+
+func (t *muxTable) compileFunc(funcKey string, h *handler) DispatchFunc {
+	next := func(ctx context.Context, _ string, p *payloadType) ([]byte, error) {
+		return h.invoke(ctx, p)
+	}
+
+	if store := t.dedupeStore; store != nil {
+		invoke := next
+		next = func(ctx context.Context, funcKey string, p *payloadType) ([]byte, error) {
+			if p.IdempotencyKey == "" {
+				return invoke(ctx, funcKey, p)
+			}
+
+			return dedupe(ctx, store, funcKey, p, invoke)
+		}
+	}
+
+	next = chainMiddlewares(h.limit(next), t.funcMiddlewares[funcKey])
+	return t.checkSchema(h, next)
+}
+
+// Use appends middlewares that run for every call, including unknown funcKeys.
+func (m *Mux) Use(mws ...Middleware) {
+	m.update(func(t *muxTable) {
+		t.middlewares = append(t.middlewares, mws...)
+	})
+}
+
+// UseFor appends middlewares that run only for funcKey, inside the ones added by Use.
+func (m *Mux) UseFor(funcKey string, mws ...Middleware) {
+	m.update(func(t *muxTable) {
+		t.funcMiddlewares[funcKey] = append(t.funcMiddlewares[funcKey], mws...)
+	})
+}
 
 func (m *Mux) Invoke(ctx context.Context, payload []byte) (res []byte, err error) {
 	var p payloadType
@@ -92,86 +198,254 @@ func (m *Mux) Invoke(ctx context.Context, payload []byte) (res []byte, err error
 		return
 	}
 
-	f, ok := m.funcTable[p.FuncKey]
-	if !ok {
-		err = ErrNotFoundFunction
+	t := m.load()
+	if p.FuncKey == batchFuncKey {
+		return t.dispatchBatch(ctx, &p)
+	}
+
+	res, err = t.dispatch(ctx, &p)
+	if !p.Wrap {
 		return
 	}
 
-	return f.invoke(ctx, &p)
+	return json.Marshal(t.newResult(ctx, &p, res, err))
+}
+
+// newResult wraps the outcome of a call, encoded the way the caller of p accepts.
+func (t *muxTable) newResult(ctx context.Context, p *payloadType, res []byte, err error) *resultType {
+	result := newResult(res, err)
+
+	if p.AcceptEncoding == encodingGzip {
+		if err := result.compress(t.compressThreshold); err != nil {
+			return newResult(nil, err)
+		}
+	}
+
+	if p.AcceptBlob {
+		if err := result.offload(ctx, t.blobStore, t.blobLimit); err != nil {
+			return newResult(nil, err)
+		}
+	}
+
+	return result
+}
+
+func (t *muxTable) dispatch(ctx context.Context, p *payloadType) (res []byte, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			res, err = nil, t.recoverPanic(ctx, p.FuncKey, v)
+		}
+	}()
+
+	err = p.load(ctx, t.blobStore)
+	if err != nil {
+		return
+	}
+
+	err = p.decompress()
+	if err != nil {
+		return
+	}
+
+	ctx = withIncomingMetadata(ctx, p.Metadata)
+	if p.Deadline > 0 {
+		deadline := time.UnixMilli(p.Deadline)
+		if !time.Now().Before(deadline) {
+			return nil, ErrDeadlineExceeded
+		}
+
+		// the earlier of the caller and the lambda deadline wins
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	return t.root(ctx, p.FuncKey, p)
+}
+
+func (t *muxTable) call(ctx context.Context, funcKey string, p *payloadType) ([]byte, error) {
+	switch funcKey {
+	case pingFuncKey:
+		return nil, nil
+	case describeFuncKey:
+		return t.describe()
+	}
+
+	f, ok := t.funcs[funcKey]
+	if !ok {
+		return nil, ErrNotFoundFunction
+	}
+
+	return f(ctx, funcKey, p)
+}
+
+func chainMiddlewares(next DispatchFunc, mws []Middleware) DispatchFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		next = mws[i](next)
+	}
+
+	return next
+}
+
+// WithCall makes Mux call the function through call instead of reflection, like the code lamlam gen writes.
+// The function given to Set still tells the types of its arguments and results.
+func WithCall(call CallFunc) SetOption {
+	return func(h *handler) {
+		h.call = call
+	}
 }
 
-func (m *Mux) Set(funcKey string, f interface{}) error {
+// WithParamNames names the data arguments of a function taking several, keying the object its data travels in.
+// Without it they are named arg0, arg1 and so on.
+func WithParamNames(names ...string) SetOption {
+	return func(h *handler) {
+		h.paramNames = names
+	}
+}
+
+// JoinFuncOptions returns the options of every one of opts, in order.
+func JoinFuncOptions(opts ...FuncOptions) FuncOptions {
+	if len(opts) == 1 {
+		return opts[0]
+	}
+
+	joined := make(FuncOptions)
+	for _, o := range opts {
+		for funcKey, setOpts := range o {
+			joined[funcKey] = append(joined[funcKey], setOpts...)
+		}
+	}
+
+	return joined
+}
+
+// For returns base followed by the options of funcKey.
+func (o FuncOptions) For(funcKey string, base ...SetOption) []SetOption {
+	return append(base, o[funcKey]...)
+}
+
+func (m *Mux) Set(funcKey string, f interface{}, opts ...SetOption) error {
 	funcValue := reflect.ValueOf(f)
-	return m.setHandler(funcKey, &handler{
+	h := &handler{
 		funcValue: funcValue,
 		funcType:  funcValue.Type(),
-	})
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return m.setHandler(funcKey, h)
 }
 
 func (m *Mux) setHandler(funcKey string, h *handler) error {
+	if err := checkFuncKey(funcKey); err != nil {
+		return err
+	}
+	if err := h.init(); err != nil {
+		return err
+	}
+
+	m.update(func(t *muxTable) {
+		t.funcTable[funcKey] = h
+	})
+	return nil
+}
+
+// checkFuncKey rejects reserved funcKeys, whose handlers Mux would never call.
+func checkFuncKey(funcKey string) error {
+	if strings.HasPrefix(funcKey, reservedFuncKeyPrefix) {
+		return fmt.Errorf("funcKey %q is reserved", funcKey)
+	}
+
+	return nil
+}
+
+// init checks the function of h and tells how to call it.
+func (h *handler) init() error {
 	if h.funcValue.Kind() != reflect.Func {
 		return errors.New("not function")
 	}
 
 	numIn := h.funcType.NumIn()
-	if numIn > 2 {
-		return errors.New("func args number must be under the three")
-	}
-
+	h.funcInputType = make([]reflect.Type, numIn)
 	for i := 0; i < numIn; i++ {
 		h.funcInputType[i] = h.funcType.In(i)
+		if i > 0 && h.funcInputType[i] == contextType {
+			return errors.New("\"context.Context\" must be the first argument")
+		}
+	}
+
+	h.withContext = numIn > 0 && h.funcInputType[0] == contextType
+	numData := numIn
+	if h.withContext {
+		numData--
 	}
 
-	switch numIn {
-	case 0:
+	switch {
+	case numData == 0 && !h.withContext:
 		h.inputKind = inputNothing
-	case 1:
-		if h.funcInputType[0] == contextType {
-			h.inputKind = inputContextOnly
-		} else {
-			h.inputKind = inputDataOnly
+	case numData == 0:
+		h.inputKind = inputContextOnly
+	case numData == 1 && !h.withContext:
+		h.inputKind = inputDataOnly
+	case numData == 1:
+		h.inputKind = inputBoth
+	default:
+		// several data arguments travel as an object keyed by parameter name
+		h.inputKind = inputParams
+		if len(h.paramNames) == 0 {
+			h.paramNames = make([]string, numData)
+			for i := range h.paramNames {
+				h.paramNames[i] = fmt.Sprintf("arg%d", i)
+			}
 		}
-	case 2:
-		if h.funcInputType[0] != contextType {
-			return errors.New("if tow args, first argument must be \"context.Context\"")
+		if len(h.paramNames) != numData {
+			return errors.New("param names number must match the data arguments")
 		}
-		h.inputKind = inputBoth
 	}
 
 	numOut := h.funcType.NumOut()
-	if numOut > 2 {
-		return errors.New("func returns number must be under the three")
-	}
-
+	h.funcOutputType = make([]reflect.Type, numOut)
 	for i := 0; i < numOut; i++ {
 		h.funcOutputType[i] = h.funcType.Out(i)
+		if i < numOut-1 && h.funcOutputType[i] == errorType {
+			return errors.New("\"error\" must be the last return")
+		}
 	}
 
-	switch numOut {
-	case 0:
-		h.outputKind = outputNothing
-	case 1:
-		if h.funcOutputType[0] == errorType {
-			h.outputKind = outputErrorOnly
-		} else {
-			h.outputKind = outputDataOnly
-		}
-	case 2:
-		if h.funcOutputType[1] != errorType {
-			return errors.New("if 2 returns, second return must be \"error\"")
-		}
+	h.withError = numOut > 0 && h.funcOutputType[numOut-1] == errorType
+	numData = numOut
+	if h.withError {
+		numData--
+	}
 
+	switch {
+	case numData == 0 && !h.withError:
+		h.outputKind = outputNothing
+	case numData == 0:
+		h.outputKind = outputErrorOnly
+	case numData == 1 && !h.withError:
+		h.outputKind = outputDataOnly
+	case numData == 1:
 		h.outputKind = outputBoth
+	default:
+		// several data returns travel as a tuple
+		h.outputKind = outputResults
 	}
 
-	m.tableLock.Lock()
-	defer m.tableLock.Unlock()
-	m.funcTable[funcKey] = h
 	return nil
 }
 
 func (h *handler) invoke(ctx context.Context, payload *payloadType) ([]byte, error) {
+	if h.call != nil {
+		res, err := h.call(ctx, payload)
+		if err != nil || res == nil {
+			return nil, err
+		}
+
+		return json.Marshal(res)
+	}
+
 	var inValues []reflect.Value
 
 	switch h.inputKind {
@@ -191,9 +465,24 @@ func (h *handler) invoke(ctx context.Context, payload *payloadType) ([]byte, err
 			return nil, err
 		}
 		inValues = []reflect.Value{reflect.ValueOf(ctx), val}
+	case inputParams:
+		vals, err := h.getParamValues(payload)
+		if err != nil {
+			return nil, err
+		}
+		if h.withContext {
+			inValues = append([]reflect.Value{reflect.ValueOf(ctx)}, vals...)
+		} else {
+			inValues = vals
+		}
 	}
 
-	resValues := h.funcValue.Call(inValues)
+	var resValues []reflect.Value
+	if h.funcType.IsVariadic() {
+		resValues = h.funcValue.CallSlice(inValues)
+	} else {
+		resValues = h.funcValue.Call(inValues)
+	}
 
 	var res []byte
 	var err error
@@ -205,11 +494,21 @@ func (h *handler) invoke(ctx context.Context, payload *payloadType) ([]byte, err
 	case outputDataOnly:
 		res, err = valueJsonMarshal(resValues[0])
 	case outputBoth:
-		res, err = valueJsonMarshal(resValues[0])
+		// the result of a failed call is dropped, like the call path does
+		setErrorSafety(&err, resValues[1])
 		if err != nil {
 			break
 		}
-		setErrorSafety(&err, resValues[1])
+		res, err = valueJsonMarshal(resValues[0])
+	case outputResults:
+		if h.withError {
+			setErrorSafety(&err, resValues[len(resValues)-1])
+			if err != nil {
+				break
+			}
+			resValues = resValues[:len(resValues)-1]
+		}
+		res, err = valuesJsonMarshal(resValues)
 	}
 
 	return res, err
@@ -231,9 +530,43 @@ func valueJsonMarshal(val reflect.Value) ([]byte, error) {
 	return json.Marshal(intf)
 }
 
+// getParamValues decodes the data arguments from an object keyed by parameter name.
+func (h *handler) getParamValues(payload *payloadType) ([]reflect.Value, error) {
+	types := h.funcInputType
+	if h.withContext {
+		types = types[1:]
+	}
+
+	ptrs := make([]any, len(types))
+	for i, typ := range types {
+		ptrs[i] = reflect.New(typ).Interface()
+	}
+
+	err := payload.BindParams(h.paramNames, ptrs...)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make([]reflect.Value, len(types))
+	for i := range ptrs {
+		vals[i] = reflect.ValueOf(ptrs[i]).Elem()
+	}
+
+	return vals, nil
+}
+
+func valuesJsonMarshal(vals []reflect.Value) ([]byte, error) {
+	tuple := make([]interface{}, len(vals))
+	for i, val := range vals {
+		tuple[i] = val.Interface()
+	}
+
+	return json.Marshal(tuple)
+}
+
 func getValueFromPayload(typ reflect.Type, payload *payloadType) (res reflect.Value, err error) {
 	val := reflect.New(typ)
-	err = payload.bindData(val.Interface())
+	err = payload.Bind(val.Interface())
 	if err != nil {
 		return
 	}
@@ -0,0 +1,273 @@
+package lamlam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type (
+	benchIn struct {
+		Id    string   `json:"id"`
+		Count int      `json:"count"`
+		Tags  []string `json:"tags"`
+	}
+
+	benchOut struct {
+		Id    string `json:"id"`
+		Total int    `json:"total"`
+	}
+)
+
+func benchFunc(_ context.Context, in benchIn) (benchOut, error) {
+	return benchOut{Id: in.Id, Total: in.Count * len(in.Tags)}, nil
+}
+
+func benchPayload(b *testing.B) []byte {
+	payload, err := json.Marshal(&payloadType{
+		FuncKey: "bench",
+		Data:    json.RawMessage(`{"id":"user-1","count":3,"tags":["a","b","c"]}`),
+		Wrap:    true,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return payload
+}
+
+func benchmarkMux(b *testing.B, m *Mux) {
+	payload := benchPayload(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Invoke(ctx, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMuxInvokeReflect(b *testing.B) {
+	m := NewMux()
+	if err := m.Set("bench", benchFunc); err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarkMux(b, m)
+}
+
+func BenchmarkMuxInvokeWithCall(b *testing.B) {
+	m := NewMux()
+	err := m.Set("bench", benchFunc, WithCall(func(ctx context.Context, p *Payload) (any, error) {
+		var in benchIn
+		if err := p.Bind(&in); err != nil {
+			return nil, err
+		}
+		return benchFunc(ctx, in)
+	}))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarkMux(b, m)
+}
+
+func BenchmarkMuxInvokeHandle(b *testing.B) {
+	m := NewMux()
+	if err := Handle(m, "bench", benchFunc); err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarkMux(b, m)
+}
+
+func BenchmarkMuxInvokeParallel(b *testing.B) {
+	m := NewMux()
+	if err := Handle(m, "bench", benchFunc); err != nil {
+		b.Fatal(err)
+	}
+
+	payload := benchPayload(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := m.Invoke(ctx, payload); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func pathFunc(_ context.Context, in benchIn) (benchOut, error) {
+	switch in.Id {
+	case "limit":
+		return benchOut{}, ErrLimitExceeded
+	case "plain":
+		return benchOut{}, errors.New("plain failure")
+	case "panic":
+		panic("boom")
+	}
+
+	return benchOut{Id: in.Id, Total: in.Count * len(in.Tags)}, nil
+}
+
+// pathMuxes registers pathFunc the three ways a function reaches Mux.
+func pathMuxes(t *testing.T) map[string]*Mux {
+	muxes := map[string]*Mux{
+		"reflect":  NewMux(),
+		"withCall": NewMux(),
+		"handle":   NewMux(),
+	}
+
+	err := muxes["reflect"].Set("path", pathFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = muxes["withCall"].Set("path", pathFunc, WithCall(func(ctx context.Context, p *Payload) (any, error) {
+		var in benchIn
+		if err := p.Bind(&in); err != nil {
+			return nil, err
+		}
+		return pathFunc(ctx, in)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Handle(muxes["handle"], "path", pathFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return muxes
+}
+
+func TestMuxPathsAgree(t *testing.T) {
+	payloads := []string{
+		`{"funcKey":"path","data":{"id":"user-1","count":3,"tags":["a","b","c"]}}`,
+		`{"funcKey":"path","data":{"id":"user-1","count":3,"tags":["a","b","c"]},"wrap":true}`,
+		`{"funcKey":"path","data":null,"wrap":true}`,
+		`{"funcKey":"path","wrap":true}`,
+		`{"funcKey":"path","data":{"count":"three"},"wrap":true}`,
+		`{"funcKey":"path","data":"user-1","wrap":true}`,
+		`{"funcKey":"path","data":{"id":"limit"},"wrap":true}`,
+		`{"funcKey":"path","data":{"id":"limit"}}`,
+		`{"funcKey":"path","data":{"id":"plain"},"wrap":true}`,
+		`{"funcKey":"path","data":{"id":"plain"}}`,
+		`{"funcKey":"path","data":{"id":"panic"},"wrap":true}`,
+		`{"funcKey":"missing","wrap":true}`,
+		`{"funcKey":"path","data":{"id":"user-1"},"wrap":true,"deadline":1}`,
+	}
+
+	muxes := pathMuxes(t)
+	for _, payload := range payloads {
+		want, wantErr := muxes["reflect"].Invoke(context.Background(), []byte(payload))
+		for _, name := range []string{"withCall", "handle"} {
+			got, gotErr := muxes[name].Invoke(context.Background(), []byte(payload))
+			if string(got) != string(want) {
+				t.Errorf("%s %s: result %s, reflection %s", name, payload, got, want)
+			}
+			if fmt.Sprintf("%T %v", gotErr, gotErr) != fmt.Sprintf("%T %v", wantErr, wantErr) {
+				t.Errorf("%s %s: error %v, reflection %v", name, payload, gotErr, wantErr)
+			}
+		}
+	}
+}
+
+func TestMuxSetWhileInvoke(t *testing.T) {
+	m := NewMux()
+	if err := Handle(m, "bench", benchFunc); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte(`{"funcKey":"bench","data":{"id":"user-1","count":3,"tags":["a"]},"wrap":true}`)
+	want, err := m.Invoke(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				res, err := m.Invoke(context.Background(), payload)
+				if err != nil || string(res) != string(want) {
+					t.Errorf("invoke: %s, %v", res, err)
+					return
+				}
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				key := fmt.Sprintf("extra-%d-%d", i, j)
+				if err := m.Set(key, func() error { return nil }, WithRateLimit(100, 1)); err != nil {
+					t.Error(err)
+					return
+				}
+				m.UseFor(key, func(next DispatchFunc) DispatchFunc { return next })
+				m.SetBatchConcurrency(j % 3)
+				m.SetSchemaCheck(SchemaCheckWarn, func(ctx context.Context, funcKey, caller, handler string) {})
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func transferFunc(_ context.Context, from, to string, amount int64) (string, error) {
+	return fmt.Sprintf("%s->%s:%d", from, to, amount), nil
+}
+
+func TestMuxParamPathsAgree(t *testing.T) {
+	names := WithParamNames("from", "to", "amount")
+	reflectMux, callMux := NewMux(), NewMux()
+	if err := reflectMux.Set("transfer", transferFunc, names); err != nil {
+		t.Fatal(err)
+	}
+
+	// the way lamlam gen writes it
+	err := callMux.Set("transfer", transferFunc, names, WithCall(func(ctx context.Context, p *Payload) (any, error) {
+		var args struct {
+			Arg0 string
+			Arg1 string
+			Arg2 int64
+		}
+		if err := p.BindParams([]string{"from", "to", "amount"}, &args.Arg0, &args.Arg1, &args.Arg2); err != nil {
+			return nil, err
+		}
+		return transferFunc(ctx, args.Arg0, args.Arg1, args.Arg2)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{
+		`{"from":"a","to":"b","amount":5}`,
+		`{"amount":5,"to":"b","from":"a"}`,
+		`{"from":"a","to":"b"}`,
+		`{"from":"a","to":"b","amount":5,"memo":"x"}`,
+		`{"arg0":"a","arg1":"b","arg2":5}`,
+		`{"from":"a","to":"b","amount":"five"}`,
+		`["a","b",5]`,
+		`null`,
+	} {
+		payload := []byte(`{"funcKey":"transfer","wrap":true,"data":` + data + `}`)
+		want, wantErr := reflectMux.Invoke(context.Background(), payload)
+		got, gotErr := callMux.Invoke(context.Background(), payload)
+		if string(got) != string(want) || gotErr != wantErr {
+			t.Errorf("%s: %s, %v, reflection %s, %v", data, got, gotErr, want, wantErr)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package lamlam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+const maxPanicStackDepth = 32
+
+var lamlamFuncPrefix = reflect.TypeOf(Mux{}).PkgPath() + "."
+
+// PanicError reports a panic recovered by Mux, match it with errors.Is(err, ErrPanic).
+type PanicError struct {
+	Value string `json:"value"`
+	Stack string `json:"stack,omitempty"`
+}
+
+// PanicHook observes panics recovered by Mux.
+type PanicHook func(ctx context.Context, funcKey string, err *PanicError)
+
+func (err *PanicError) Error() string {
+	return "panic: " + err.Value
+}
+
+func (err *PanicError) Is(target error) bool {
+	return target == ErrPanic
+}
+
+func (err *PanicError) MarshalErrorPayload(ep *ErrorPayload) (e error) {
+	ep.ErrorData, e = json.Marshal(err)
+	return
+}
+
+// OnPanic sets the hook called with every panic Mux recovers.
+func (m *Mux) OnPanic(hook PanicHook) {
+	m.update(func(t *muxTable) {
+		t.panicHook = hook
+	})
+}
+
+func (t *muxTable) recoverPanic(ctx context.Context, funcKey string, v interface{}) *PanicError {
+	err := newPanicError(v, 4)
+	if t.panicHook != nil {
+		t.panicHook(ctx, funcKey, err)
+	}
+
+	return err
+}
+
+func newPanicError(v interface{}, skip int) *PanicError {
+	err := &PanicError{}
+	if e, ok := v.(error); ok {
+		err.Value = e.Error()
+	} else {
+		err.Value = fmt.Sprint(v)
+	}
+
+	var pcs [maxPanicStackDepth * 2]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(skip, pcs[:])])
+
+	var b strings.Builder
+	depth := 0
+	for more := true; more && depth < maxPanicStackDepth; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+
+		// frames of the runtime raising the panic come first,
+		// frames of reflect and lamlam calling into the handler come last
+		if depth == 0 && strings.HasPrefix(frame.Function, "runtime.") {
+			continue
+		}
+		if depth > 0 && (strings.HasPrefix(frame.Function, "reflect.") || strings.HasPrefix(frame.Function, lamlamFuncPrefix)) {
+			break
+		}
+
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		depth++
+	}
+	err.Stack = b.String()
+	return err
+}
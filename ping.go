@@ -0,0 +1,94 @@
+package lamlam
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pingFuncKey answers with nothing once Mux is up, without calling a handler.
+const pingFuncKey = "__lamlam.ping"
+
+type PreflightMode int
+
+const (
+	// PreflightDryRun checks the function exists and the caller may invoke it, without running it.
+	PreflightDryRun PreflightMode = iota
+	// PreflightPing runs the function and checks its Mux answers.
+	PreflightPing
+)
+
+// PreflightError lists the functions failing Preflight by name.
+type PreflightError struct {
+	Errors map[string]error
+}
+
+func (err *PreflightError) Error() string {
+	names := make([]string, 0, len(err.Errors))
+	for name := range err.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("preflight failed: ")
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(fmt.Sprintf("%s: %v", name, err.Errors[name]))
+	}
+
+	return b.String()
+}
+
+// Ping checks the lambda is up and served by a Mux.
+func (i *Invoker) Ping(ctx context.Context) error {
+	return i.Invoke(ctx, pingFuncKey, nil).Result(nil)
+}
+
+// DryRun checks the lambda exists and the caller may invoke it, without running it.
+func (i *Invoker) DryRun(ctx context.Context) error {
+	return i.Func(pingFuncKey).
+		InvocationType(InvocationDryRun).
+		Invoke(ctx, nil).
+		Result(nil)
+}
+
+// Preflight checks every function of names at once, meant for service boot.
+// Failures are reported together in a *PreflightError.
+func Preflight(ctx context.Context, transport Transport, mode PreflightMode, names ...string) error {
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[string]error)
+	)
+
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			invoker := NewInvoker(transport, name)
+			check := invoker.DryRun
+			if mode == PreflightPing {
+				check = invoker.Ping
+			}
+
+			if err := check(ctx); err != nil {
+				lock.Lock()
+				errs[name] = err
+				lock.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &PreflightError{Errors: errs}
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package lamlam
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"reflect"
+	"sync"
+)
+
+type registeredCode struct {
+	code string
+	err  error
+}
+
+var (
+	errorRegistryLock sync.RWMutex
+	errorTypeRegistry = make(map[string]reflect.Type)
+	errorCodeRegistry = make(map[string]error)
+	errorCodes        []registeredCode
+)
+
+// RegisterError registers the type of err under its package path and name.
+// Payloads naming that type decode into a new value of it, with ErrorData applied.
+// Like gob.Register it panics on conflicting registrations.
+func RegisterError(err error) {
+	if err == nil {
+		panic("lamlam: RegisterError of nil error")
+	}
+
+	typ := reflect.TypeOf(err)
+	name := qualifiedTypeName(typ)
+	if name == "" {
+		panic(fmt.Sprintf("lamlam: RegisterError of unnamed type %s", typ))
+	}
+
+	errorRegistryLock.Lock()
+	defer errorRegistryLock.Unlock()
+	if registered, ok := errorTypeRegistry[name]; ok {
+		if registered == typ {
+			return
+		}
+		panic(fmt.Sprintf("lamlam: RegisterError of %s, already registered as %s", typ, registered))
+	}
+	if _, ok := errorCodeRegistry[name]; ok {
+		panic(fmt.Sprintf("lamlam: RegisterError of %s, already registered as code", name))
+	}
+
+	errorTypeRegistry[name] = typ
+}
+
+// RegisterErrorCode registers the sentinel err under a stable code.
+// Payloads naming code decode to err itself, so errors.Is works on the client.
+func RegisterErrorCode(code string, err error) {
+	if err == nil {
+		panic("lamlam: RegisterErrorCode of nil error")
+	}
+	if code == "" {
+		panic("lamlam: RegisterErrorCode with empty code")
+	}
+	if !reflect.TypeOf(err).Comparable() {
+		panic(fmt.Sprintf("lamlam: RegisterErrorCode of not comparable %T", err))
+	}
+
+	errorRegistryLock.Lock()
+	defer errorRegistryLock.Unlock()
+	if registered, ok := errorCodeRegistry[code]; ok {
+		if registered == err {
+			return
+		}
+		panic(fmt.Sprintf("lamlam: RegisterErrorCode of %q, already registered", code))
+	}
+	if _, ok := errorTypeRegistry[code]; ok {
+		panic(fmt.Sprintf("lamlam: RegisterErrorCode of %q, already registered as type", code))
+	}
+	for _, registered := range errorCodes {
+		if registered.err == err {
+			panic(fmt.Sprintf("lamlam: RegisterErrorCode of %q, already registered as %q", code, registered.code))
+		}
+	}
+
+	errorCodeRegistry[code] = err
+	errorCodes = append(errorCodes, registeredCode{code: code, err: err})
+}
+
+// lookupRegisteredError walks the chain of err and returns the first registered error with its name.
+func lookupRegisteredError(err error) (name string, found error, ok bool) {
+	errorRegistryLock.RLock()
+	defer errorRegistryLock.RUnlock()
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		if code, ok := lookupErrorCodeLocked(err); ok {
+			return code, err, true
+		}
+
+		typ := reflect.TypeOf(err)
+		name := qualifiedTypeName(typ)
+		if errorTypeRegistry[name] == typ {
+			return name, err, true
+		}
+	}
+
+	return "", nil, false
+}
+
+func lookupErrorCode(err error) (string, bool) {
+	errorRegistryLock.RLock()
+	defer errorRegistryLock.RUnlock()
+	return lookupErrorCodeLocked(err)
+}
+
+func lookupErrorCodeLocked(err error) (string, bool) {
+	if !reflect.TypeOf(err).Comparable() {
+		return "", false
+	}
+
+	for _, registered := range errorCodes {
+		if registered.err == err {
+			return registered.code, true
+		}
+	}
+
+	return "", false
+}
+
+// newRegisteredError returns the sentinel registered as name or a new value of the type registered as name.
+func newRegisteredError(name string, ep *ErrorPayload) (error, bool) {
+	errorRegistryLock.RLock()
+	sentinel, isCode := errorCodeRegistry[name]
+	typ, isType := errorTypeRegistry[name]
+	errorRegistryLock.RUnlock()
+
+	switch {
+	case isCode:
+		return sentinel, true
+	case isType:
+		base := typ
+		for base.Kind() == reflect.Ptr {
+			base = base.Elem()
+		}
+
+		val := reflect.New(base)
+		if ep.unmarshalTo(val.Interface()) != nil {
+			return nil, false
+		}
+
+		if typ.Kind() != reflect.Ptr {
+			val = val.Elem()
+		}
+
+		err, ok := val.Interface().(error)
+		return err, ok
+	}
+
+	return nil, false
+}
+
+func qualifiedTypeName(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Name() == "" {
+		return ""
+	}
+
+	if typ.PkgPath() == "" {
+		return typ.Name()
+	}
+
+	return typ.PkgPath() + "." + typ.Name()
+}
+
+func isExportedType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	return token.IsExported(typ.Name())
+}
@@ -0,0 +1,144 @@
+package lamlam
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = 50 * time.Millisecond
+	defaultRetryMaxDelay  = 2 * time.Second
+)
+
+// Retryability tells whether a failed call may be sent again.
+type Retryability int
+
+const (
+	// RetryNever means the error is final.
+	RetryNever Retryability = iota
+	// RetrySafe means the function did not run, so any call may be retried.
+	RetrySafe
+	// RetryIdempotent means the function may have run, so only idempotent calls are retried.
+	RetryIdempotent
+)
+
+type (
+	RetryClassifier func(err error) Retryability
+
+	// RetryPolicy retries failed calls with exponential backoff and full jitter.
+	RetryPolicy struct {
+		MaxAttempts int           // including the first attempt, under 2 disables retrying
+		BaseDelay   time.Duration // delay before the second attempt, doubled on every attempt after
+		MaxDelay    time.Duration
+		Classify    RetryClassifier // DefaultRetryClassifier if nil
+	}
+)
+
+var (
+	jitterLock sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// DefaultRetryClassifier retries throttling, rejections by limits and duplicates still in progress of any call,
+// service and network errors after which the function may have run only for idempotent calls.
+func DefaultRetryClassifier(err error) Retryability {
+	var (
+		tooManyRequests  *types.TooManyRequestsException
+		ec2Throttled     *types.EC2ThrottledException
+		resourceNotReady *types.ResourceNotReadyException
+		service          *types.ServiceException
+		netErr           net.Error
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return RetryNever
+	case errors.Is(err, ErrInProgress), errors.Is(err, ErrLimitExceeded), errors.As(err, &tooManyRequests), errors.As(err, &ec2Throttled), errors.As(err, &resourceNotReady):
+		return RetrySafe
+	case errors.As(err, &service), errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return RetryIdempotent
+	}
+
+	return RetryNever
+}
+
+// SetRetryPolicy sets the retry policy of handlers created by Func afterwards.
+func (i *Invoker) SetRetryPolicy(policy RetryPolicy) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.retryPolicy = policy
+}
+
+// Idempotent marks the call safe to retry even after the function may have run.
+func (i *Handler) Idempotent() *Handler {
+	i.idempotent = true
+	return i
+}
+
+// withRetry runs attempt until it succeeds, or the error, the policy or ctx stops it.
+func (i *Handler) withRetry(ctx context.Context, attempt func() ([]byte, error)) ([]byte, error) {
+	for n := 1; ; n++ {
+		data, err := attempt()
+		if err == nil || !i.retryPolicy.shouldRetry(n, data, err, i.idempotent) {
+			return data, err
+		}
+
+		timer := time.NewTimer(i.retryPolicy.backoff(n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return data, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (p *RetryPolicy) shouldRetry(attempt int, data []byte, err error, idempotent bool) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+
+	// function errors are classified by the error they carry
+	if err == ErrUnhandled {
+		err = (&Return{data: data, err: err}).Result(nil)
+	}
+
+	classify := p.Classify
+	if classify == nil {
+		classify = DefaultRetryClassifier
+	}
+
+	switch classify(err) {
+	case RetrySafe:
+		return true
+	case RetryIdempotent:
+		return idempotent
+	}
+
+	return false
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	base, max := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	if max <= 0 {
+		max = defaultRetryMaxDelay
+	}
+
+	delay := max
+	if shift := attempt - 1; shift < 32 && base<<shift < max {
+		delay = base << shift
+	}
+
+	jitterLock.Lock()
+	defer jitterLock.Unlock()
+	return time.Duration(jitterRand.Int63n(int64(delay) + 1))
+}
@@ -0,0 +1,72 @@
+package lamlam
+
+import (
+	"context"
+	"log"
+)
+
+type (
+	// SchemaCheck is what Mux does when the schema hash of a call differs from the one of its handler.
+	SchemaCheck int
+
+	// SchemaMismatchHook observes calls whose schema hash differs from the one of the handler.
+	SchemaMismatchHook func(ctx context.Context, funcKey, callerHash, handlerHash string)
+)
+
+const (
+	// SchemaCheckOff ignores schema hashes, the default.
+	SchemaCheckOff SchemaCheck = iota
+	// SchemaCheckWarn calls the handler anyway, after reporting the mismatch.
+	SchemaCheckWarn
+	// SchemaCheckReject reports the mismatch and fails the call with ErrSchemaMismatch.
+	SchemaCheckReject
+)
+
+// WithSchemaHash sets the schema hash lamlam gen computed for the handler.
+func WithSchemaHash(hash string) SetOption {
+	return func(h *handler) {
+		h.schemaHash = hash
+	}
+}
+
+// SchemaHash sends the schema hash lamlam gen computed for the method, so Mux can tell a caller out of date.
+func (i *Handler) SchemaHash(hash string) *Handler {
+	i.payload.SchemaHash = hash
+	return i
+}
+
+// SetSchemaCheck sets how Mux treats calls whose schema hash differs from the one of the handler.
+// Mismatches are reported to hook, or to the standard logger if hook is nil.
+// Calls or handlers without a hash are never checked.
+func (m *Mux) SetSchemaCheck(check SchemaCheck, hook SchemaMismatchHook) {
+	m.update(func(t *muxTable) {
+		t.schemaCheck = check
+		t.schemaMismatchHook = hook
+	})
+}
+
+// checkSchema wraps next of h with the schema check, if there is any.
+func (t *muxTable) checkSchema(h *handler, next DispatchFunc) DispatchFunc {
+	check, hook := t.schemaCheck, t.schemaMismatchHook
+	if check == SchemaCheckOff || h.schemaHash == "" {
+		return next
+	}
+
+	return func(ctx context.Context, funcKey string, p *payloadType) ([]byte, error) {
+		if p.SchemaHash == "" || p.SchemaHash == h.schemaHash {
+			return next(ctx, funcKey, p)
+		}
+
+		if hook != nil {
+			hook(ctx, funcKey, p.SchemaHash, h.schemaHash)
+		} else {
+			log.Printf("lamlam: schema mismatch on %s, caller %s, handler %s", funcKey, p.SchemaHash, h.schemaHash)
+		}
+
+		if check == SchemaCheckReject {
+			return nil, ErrSchemaMismatch
+		}
+
+		return next(ctx, funcKey, p)
+	}
+}
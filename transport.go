@@ -0,0 +1,14 @@
+package lamlam
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// Transport delivers an invocation to a lambda function.
+// *lambda.Client implements it, fakes and in-process transports can stand in for it.
+type Transport interface {
+	Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error)
+}
+
+var _ Transport = (*lambda.Client)(nil)
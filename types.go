@@ -1,10 +1,81 @@
 package lamlam
 
-import "encoding/json"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Payload is the envelope Mux decodes from every invocation.
+type Payload = payloadType
 
 type payloadType struct {
-	FuncKey string          `json:"funcKey"`
-	Data    json.RawMessage `json:"data"`
+	FuncKey        string          `json:"funcKey"`
+	Data           json.RawMessage `json:"data"`
+	Encoding       string          `json:"encoding,omitempty"`
+	AcceptEncoding string          `json:"acceptEncoding,omitempty"`
+	Blob           string          `json:"blob,omitempty"`
+	AcceptBlob     bool            `json:"acceptBlob,omitempty"`
+	Metadata       Metadata        `json:"metadata,omitempty"`
+	Deadline       int64           `json:"deadline,omitempty"` // unix milliseconds, absolute so queueing counts against it
+	Wrap           bool            `json:"wrap,omitempty"`
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"`
+	SchemaHash     string          `json:"schemaHash,omitempty"`
+	Batch          []payloadType   `json:"batch,omitempty"`
+}
+
+// resultType is the response envelope Mux returns when the payload asks for Wrap.
+// Errors travel inside it, so fields the lambda runtime would drop survive.
+// Wrapped is always set, telling it apart from the raw result of a Mux older than the envelope.
+type resultType struct {
+	Wrapped  bool            `json:"wrapped"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Encoding string          `json:"encoding,omitempty"`
+	Blob     string          `json:"blob,omitempty"`
+	Error    *ErrorPayload   `json:"error,omitempty"`
+}
+
+func newResult(data []byte, err error) *resultType {
+	if err != nil {
+		return &resultType{Wrapped: true, Error: newErrorPayload(err)}
+	}
+
+	return &resultType{Wrapped: true, Data: data}
+}
+
+func (r *resultType) result(ctx context.Context, store BlobStore) ([]byte, error) {
+	if r.Error != nil {
+		return nil, r.Error.TryCastKnownError()
+	}
+
+	if err := r.load(ctx, store); err != nil {
+		return nil, err
+	}
+
+	if err := r.decompress(); err != nil {
+		return nil, err
+	}
+
+	return r.Data, nil
+}
+
+// unwrapResult decodes the result envelope in data. A Mux older than the envelope ignores Wrap
+// and sends the raw result, which is returned as is.
+func unwrapResult(ctx context.Context, data []byte, store BlobStore) ([]byte, error) {
+	var result resultType
+	if !isJSONObject(data) || json.Unmarshal(data, &result) != nil || !result.Wrapped {
+		return data, nil
+	}
+
+	return result.result(ctx, store)
+}
+
+func isJSONObject(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '{'
 }
 
 func (p *payloadType) setData(src any) error {
@@ -17,6 +88,56 @@ func (p *payloadType) setData(src any) error {
 	return nil
 }
 
-func (p *payloadType) bindData(dst any) error {
+// Bind decodes the data of the payload into dst.
+func (p *payloadType) Bind(dst any) error {
 	return json.Unmarshal(p.Data, dst)
 }
+
+// BindParams decodes the data of the payload, an object keyed by names, into dsts in order.
+// A missing or unexpected name fails, so a caller naming the parameters otherwise never calls with zero values.
+func (p *payloadType) BindParams(names []string, dsts ...any) error {
+	if len(names) != len(dsts) {
+		return errors.New("param names number must match the destinations")
+	}
+
+	seen := make([]bool, len(names))
+	dec := json.NewDecoder(bytes.NewReader(p.Data))
+	tok, err := dec.Token()
+	switch {
+	case err == io.EOF, err == nil && tok == nil:
+		// no data, every param is missing
+	case err != nil:
+		return err
+	case tok != json.Delim('{'):
+		return errors.New("params must be an object")
+	default:
+		for dec.More() {
+			tok, err = dec.Token()
+			if err != nil {
+				return err
+			}
+
+			name, _ := tok.(string)
+			i := 0
+			for i < len(names) && names[i] != name {
+				i++
+			}
+			if i == len(names) {
+				return fmt.Errorf("unexpected param %q", name)
+			}
+
+			if err = dec.Decode(dsts[i]); err != nil {
+				return err
+			}
+			seen[i] = true
+		}
+	}
+
+	for i := range seen {
+		if !seen[i] {
+			return fmt.Errorf("missing param %q", names[i])
+		}
+	}
+
+	return nil
+}